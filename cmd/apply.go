@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/jaymccon/cloudctl/crudl"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var applyFile string
+
+var ApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "creates or updates cloud resources from manifests",
+	Long: `apply creates the resources described by manifests, or updates them if the manifest has an identifier. 
+Manifests are applied in dependency order so that they can refer to each other with Ref and Fn::GetAtt.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		bootstrapCache(cmd.Context(), os.Args[1:])
+		crudl.Apply(cmd.Context(), applyFile, noPrompts)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(ApplyCmd)
+
+	ApplyCmd.Flags().StringVarP(&applyFile, "filename", "f", "", "manifest file or directory of manifests to apply, - reads from stdin")
+	_ = ApplyCmd.MarkFlagRequired("filename")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/jaymccon/cloudctl/crudl"
+	"github.com/jaymccon/cloudctl/data"
+	"github.com/spf13/pflag"
+	"os"
+	"strings"
+)
+
+// bootstrapCache makes sure there is a schema cache to build resource commands from. On first run the user is offered
+// an upgrade, which is run without asking when prompts are disabled. If the upgrade is declined or fails the cache is
+// seeded with the core schemas embedded in the binary.
+func bootstrapCache(ctx context.Context, args []string) {
+	if data.CacheReady() {
+		return
+	}
+	// completion output is parsed by the shell, so completions only ever seed and never print
+	if completing(args) {
+		_, _ = data.SeedCache()
+		return
+	}
+	parseGlobalFlags(args)
+	fmt.Fprintln(os.Stderr, "No resource schemas have been cached yet.")
+	upgrade := noPrompts
+	if !noPrompts {
+		// an answer that can't be read seeds the cache instead
+		upgrade, _ = crudl.Confirm("Download resource schemas from the CloudFormation registry now? This can take a few minutes")
+	}
+	if upgrade {
+		err := data.UpdateCache(ctx, nil, "", false)
+		if err == nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "ERROR: downloading schemas: %q\n", err.Error())
+	}
+	count, err := data.SeedCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: seeding schema cache: %q\n", err.Error())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Seeded the cache with %d core resource types, run \"cloudctl upgrade\" to download the rest.\n", count)
+}
+
+// completing reports whether args are a shell completion request.
+func completing(args []string) bool {
+	return len(args) > 0 && strings.HasPrefix(args[0], "__complete")
+}
+
+// parseGlobalFlags parses the root command's persistent flags ahead of cobra, so that they can be used before the
+// command tree is built. Flags that belong to subcommands are ignored.
+func parseGlobalFlags(args []string) {
+	flags := pflag.NewFlagSet("global", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.Usage = func() {}
+	flags.AddFlagSet(RootCmd.PersistentFlags())
+	_ = flags.Parse(args)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"github.com/jaymccon/cloudctl/crudl"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"github.com/spf13/cobra"
+	"strings"
+	"time"
+)
+
+// completions write to stdout, which is parsed by the shell, so errors result in no completions rather than output
+
+// completionTimeout bounds the AWS calls made for a completion, so that TAB returns promptly without a network.
+const completionTimeout = 3 * time.Second
+
+func completeId(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeIdentifiers(cmd.Context(), cmd.Annotations["typeName"], "", toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeIdentifiers returns the identifiers of typeName's resources, described by their summary columns, prefixed
+// with prefix.
+func completeIdentifiers(ctx context.Context, typeName string, prefix string, toComplete string) []string {
+	ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+	defer cancel()
+	resources, err := crudl.ListResourcesCached(ctx, typeName)
+	if err != nil {
+		return nil
+	}
+	headers := append([]interface{}{"Identifier"}, crudl.GetTableHeaders(*resources)...)
+	var completeList []string
+	for _, r := range *resources {
+		if !strings.HasPrefix(prefix+*r.Identifier, toComplete) {
+			continue
+		}
+		row := crudl.GetRow(r, headers)
+		completeStr := prefix + row[0].(string) + "\t"
+		for _, i := range row[1:] {
+			completeStr = completeStr + i.(string) + " "
+		}
+		completeList = append(completeList, strings.TrimSpace(completeStr))
+	}
+	return completeList
+}
+
+// completeProperties completes Path=value flags. Paths come from the resource's schema, settable excludes read only
+// properties. Values are completed from the property's enum, or with the identifiers of the type it refers to.
+func completeProperties(settable bool) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		typeName := cmd.Annotations["typeName"]
+		schema, err := data.GetSchema(typeName)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		if strings.Contains(toComplete, "=") {
+			path, _, _ := data.ParseAssignment(toComplete)
+			return completePropertyValues(cmd.Context(), *schema, path, toComplete), cobra.ShellCompDirectiveNoFileComp
+		}
+		var completions []string
+		for _, p := range schema.PropertyPaths() {
+			if (settable && p.ReadOnly) || !strings.HasPrefix(p.Path, toComplete) {
+				continue
+			}
+			completions = append(completions, p.Path+"=\t"+awsProvider.FirstLine(p.Description))
+		}
+		return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func completePropertyValues(ctx context.Context, schema data.CfnSchema, path string, toComplete string) []string {
+	var completions []string
+	for _, p := range schema.PropertyPaths() {
+		if p.Path != path || len(p.Enum) == 0 {
+			continue
+		}
+		for _, e := range p.Enum {
+			value := path + "=" + data.ToString(e)
+			if strings.HasPrefix(value, toComplete) {
+				completions = append(completions, value)
+			}
+		}
+		return completions
+	}
+	index, err := data.GetIndex()
+	if err != nil {
+		return nil
+	}
+	parts := strings.Split(path, ".")
+	refType, ok := index.ReferencedType(schema.TypeName, parts[len(parts)-1])
+	if !ok || refType == schema.TypeName {
+		return nil
+	}
+	return completeIdentifiers(ctx, refType, path+"=", toComplete)
+}
+
+// completeIdParts completes --id-part flags with the names of the properties in the resource's primary identifier.
+func completeIdParts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	schema, err := data.GetSchema(cmd.Annotations["typeName"])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, name := range schema.PrimaryIdentifierNames() {
+		if strings.HasPrefix(name, toComplete) {
+			completions = append(completions, name+"=")
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
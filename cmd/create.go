@@ -1,7 +1,8 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
 	"github.com/jaymccon/cloudctl/crudl"
 	"github.com/jaymccon/cloudctl/data"
 	"github.com/spf13/cobra"
@@ -12,25 +13,48 @@ var CreateCmd = &cobra.Command{
 	Short: "creates cloud resources",
 }
 
-func CreateEdit(typeName string) {
+// CreateEdit creates a resource from Path=value assignments, or opens an editor with a template of the resource's
+// properties if there are none.
+func CreateEdit(ctx context.Context, typeName string, sets []string, clientToken string, noPrompts bool, async bool) {
 	schema, err := data.GetSchema(typeName)
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		crudl.ReportError(err)
 		return
 	}
-	yamlFile := data.YamlDoc{}
-	for name, props := range schema.Properties {
-		yamlFile = append(yamlFile, data.NewProp(name, props, *schema, nil, 0))
+	var jsonDoc []byte
+	if len(sets) > 0 {
+		desiredState := map[string]interface{}{}
+		for _, set := range sets {
+			path, value, err := data.ParseAssignment(set)
+			if err != nil {
+				crudl.ReportError(crudl.Invalid(err))
+				return
+			}
+			err = schema.SetProperty(desiredState, path, value)
+			if err != nil {
+				crudl.ReportError(crudl.Invalid(err))
+				return
+			}
+		}
+		jsonDoc, err = json.Marshal(desiredState)
+		if err != nil {
+			crudl.ReportError(err)
+			return
+		}
+	} else {
+		yamlFile := data.YamlDoc{}
+		for name, props := range schema.Properties {
+			yamlFile = append(yamlFile, data.NewProp(name, props, *schema, nil, 0))
+		}
+		yamlFile.Sort()
+		outp := string(yamlFile.Marshal())
+		jsonDoc, err = data.Edit(outp, "yml")
+		if err != nil {
+			crudl.ReportError(crudl.Invalid(err))
+			return
+		}
 	}
-	yamlFile.Sort()
-	outp := string(yamlFile.Marshal())
-	jsonDoc, err := data.Edit(outp, "yml")
-	if err != nil {
-		fmt.Printf("ERROR: %s", err.Error())
-		return
-	}
-	// TODO: propagate noPrompts and async
-	crudl.CreateResource(typeName, string(jsonDoc), true, false)
+	crudl.CreateResource(ctx, typeName, string(jsonDoc), clientToken, noPrompts, async)
 }
 
 func init() {
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jaymccon/cloudctl/crudl"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+// mutatingCommands change resources, they use the mutating_ role settings when mutating_role_arn is configured.
+var mutatingCommands = map[string]bool{"create": true, "update": true, "delete": true, "apply": true}
+
+// configureRole sets the role that AWS calls are made with from the role_arn, external_id, mfa_serial and
+// session_duration settings. Mutating commands use mutating_role_arn instead when it is set, so that day to day reads
+// can be made with a read-only role and changes with an elevated one, with each mutating_ setting defaulting to its
+// unprefixed value. Flags take precedence over both.
+func configureRole() {
+	prefix := ""
+	if viper.GetString("mutating_role_arn") != "" && !RootCmd.PersistentFlags().Changed("role-arn") &&
+		mutatingCommands[firstCommand(os.Args[1:])] {
+		prefix = "mutating_"
+	}
+	// flags are bound to the unprefixed settings, and are used over the config file's mutating_ settings
+	flagged := func(key string) bool {
+		return RootCmd.PersistentFlags().Changed(strings.ReplaceAll(key, "_", "-"))
+	}
+	setting := func(key string) string {
+		if v := viper.GetString(prefix + key); v != "" && !flagged(key) {
+			return v
+		}
+		return viper.GetString(key)
+	}
+	duration := viper.GetDuration(prefix + "session_duration")
+	if duration == 0 || flagged("session_duration") {
+		duration = viper.GetDuration("session_duration")
+	}
+	awsProvider.Role = awsProvider.RoleOptions{
+		RoleArn:    viper.GetString(prefix + "role_arn"),
+		ExternalId: setting("external_id"),
+		MfaSerial:  setting("mfa_serial"),
+		Duration:   duration,
+	}
+	if dir, err := data.CredentialsPath(); err == nil {
+		awsProvider.CredentialsCacheDir = dir
+	}
+	// completion output is parsed by the shell, so completions only use credentials that are already cached
+	if !noPrompts && !completing(os.Args[1:]) {
+		awsProvider.MfaTokenPrompt = func(serial string) (string, error) {
+			return crudl.Prompt(fmt.Sprintf("MFA code for %s", serial))
+		}
+	}
+}
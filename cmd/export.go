@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var ExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "exports cloud resources as infrastructure as code templates",
+}
+
+func init() {
+	RootCmd.AddCommand(ExportCmd)
+}
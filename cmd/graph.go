@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var GraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "graphs the live resources related to a cloud resource",
+}
+
+func init() {
+	RootCmd.AddCommand(GraphCmd)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/jaymccon/cloudctl/crudl"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var importParameters []string
+var importOutputDir string
+
+var ImportCmd = &cobra.Command{
+	Use:   "import <template>",
+	Short: "converts a CloudFormation template into manifests for apply",
+	Long: `import converts each resource in a CloudFormation template, in yaml or json, into a manifest. Parameters, 
+mappings, conditions and pseudo parameters are resolved, references between the template's resources are kept for 
+apply to resolve. Anything that can't be resolved is listed in the manifest's unresolved properties and has to be 
+edited before the manifest can be applied.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		bootstrapCache(cmd.Context(), os.Args[1:])
+		crudl.ImportTemplate(cmd.Context(), args[0], importParameters, importOutputDir)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(ImportCmd)
+
+	ImportCmd.Flags().StringArrayVarP(
+		&importParameters,
+		"parameter",
+		"p",
+		nil,
+		"template parameter value, eg. --parameter Environment=prod. Pseudo parameters like AWS::AccountId can be supplied too. Can be repeated.",
+	)
+	ImportCmd.Flags().StringVarP(
+		&importOutputDir,
+		"output-dir",
+		"d",
+		"",
+		"write a manifest file per resource to this directory instead of writing all manifests to stdout",
+	)
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/jaymccon/cloudctl/crudl"
 	"github.com/jaymccon/cloudctl/data"
 	"github.com/jaymccon/cloudctl/providers"
-	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
 	"github.com/spf13/cobra"
 	"strings"
 )
@@ -20,6 +19,8 @@ var ProviderUpdateCmds = map[string]*cobra.Command{}
 var ProviderDeleteCmds = map[string]*cobra.Command{}
 var ProviderListCmds = map[string]*cobra.Command{}
 var ProviderConfigureCmds = map[string]*cobra.Command{}
+var ProviderGraphCmds = map[string]*cobra.Command{}
+var ProviderExportCmds = map[string]*cobra.Command{}
 
 var ServiceCreateCmds = map[string]map[string]*cobra.Command{}
 var ServiceReadCmds = map[string]map[string]*cobra.Command{}
@@ -27,6 +28,8 @@ var ServiceUpdateCmds = map[string]map[string]*cobra.Command{}
 var ServiceDeleteCmds = map[string]map[string]*cobra.Command{}
 var ServiceListCmds = map[string]map[string]*cobra.Command{}
 var ServiceConfigureCmds = map[string]map[string]*cobra.Command{}
+var ServiceGraphCmds = map[string]map[string]*cobra.Command{}
+var ServiceExportCmds = map[string]map[string]*cobra.Command{}
 
 var ResourceCreateCmds = map[string]map[string]map[string]*cobra.Command{}
 var ResourceReadCmds = map[string]map[string]map[string]*cobra.Command{}
@@ -34,236 +37,437 @@ var ResourceUpdateCmds = map[string]map[string]map[string]*cobra.Command{}
 var ResourceDeleteCmds = map[string]map[string]map[string]*cobra.Command{}
 var ResourceListCmds = map[string]map[string]map[string]*cobra.Command{}
 var ResourceConfigureCmds = map[string]map[string]map[string]*cobra.Command{}
+var ResourceGraphCmds = map[string]map[string]map[string]*cobra.Command{}
+var ResourceExportCmds = map[string]map[string]map[string]*cobra.Command{}
 
-func init() {
-	schemas, err := data.GetSchemas()
-	if err != nil {
-		fmt.Printf("ERROR: %q\n", err)
-		return
+var setValues []string
+var clientToken string
+var whereValues []string
+var idParts []string
+var idsFile string
+var cascade bool
+var graphFormat string
+var graphDepth int
+var exportFormat string
+var exportRelated bool
+
+// verb is a top level command with a provider, service and resource command tree built from the schema index.
+type verb struct {
+	cmd       *cobra.Command
+	providers map[string]*cobra.Command
+	services  map[string]map[string]*cobra.Command
+	resources map[string]map[string]map[string]*cobra.Command
+	// include reports whether the verb applies to a resource type, a nil include applies to all types
+	include func(entry data.IndexEntry) bool
+	// run is the resource command's Run, the type name is available from the command's annotations
+	run func(cmd *cobra.Command, args []string)
+	// configure is applied to each resource command after it is created, eg. to add args validation or completion
+	configure func(cmd *cobra.Command)
+}
+
+func verbs() []verb {
+	return []verb{
+		{
+			cmd:       CreateCmd,
+			providers: ProviderCreateCmds,
+			services:  ServiceCreateCmds,
+			resources: ResourceCreateCmds,
+			run: func(cmd *cobra.Command, args []string) {
+				CreateEdit(cmd.Context(), cmd.Annotations["typeName"], setValues, clientToken, noPrompts, async)
+			},
+			configure: func(cmd *cobra.Command) {
+				cmd.Flags().StringArrayVar(
+					&setValues,
+					"set",
+					nil,
+					"set a property instead of editing a template, eg. --set VersioningConfiguration.Status=Enabled. Can be repeated.",
+				)
+				_ = cmd.RegisterFlagCompletionFunc("set", completeProperties(true))
+				cmd.Flags().StringVar(
+					&clientToken,
+					"client-token",
+					"",
+					"token that makes the create idempotent, by default it is derived from the type and properties. Creating the same properties again soon after deleting the resource needs a new token.",
+				)
+			},
+		},
+		{
+			cmd:       ReadCmd,
+			providers: ProviderReadCmds,
+			services:  ServiceReadCmds,
+			resources: ResourceReadCmds,
+			run: func(cmd *cobra.Command, args []string) {
+				refs, ids, err := identifiers(cmd, args)
+				if err != nil {
+					crudl.ReportError(crudl.Invalid(err))
+					return
+				}
+				crudl.ReadResources(cmd.Context(), cmd.Annotations["typeName"], refs, ids, output, noPrompts)
+			},
+			configure: func(cmd *cobra.Command) {
+				cmd.Args = requireIdentifiers
+				cmd.ValidArgsFunction = completeId
+				addIdPartsFlag(cmd)
+				addIdsFileFlag(cmd)
+			},
+		},
+		{
+			cmd:       UpdateCmd,
+			providers: ProviderUpdateCmds,
+			services:  ServiceUpdateCmds,
+			resources: ResourceUpdateCmds,
+			include: func(entry data.IndexEntry) bool {
+				return entry.Updatable
+			},
+			run: func(cmd *cobra.Command, args []string) {
+				refs, ids, err := identifiers(cmd, args)
+				if err != nil {
+					crudl.ReportError(crudl.Invalid(err))
+					return
+				}
+				ids = append(refs, ids...)
+				if len(ids) != 1 {
+					crudl.ReportError(crudl.Invalidf("update command requires an identifier to be supplied as a single argument or with --id-part"))
+					return
+				}
+				crudl.UpdateResource(cmd.Context(), cmd.Annotations["typeName"], ids[0], setValues, noPrompts, async)
+			},
+			configure: func(cmd *cobra.Command) {
+				cmd.ValidArgsFunction = completeId
+				addIdPartsFlag(cmd)
+				cmd.Flags().StringArrayVar(
+					&setValues,
+					"set",
+					nil,
+					"set a property instead of editing the current state, eg. --set VersioningConfiguration.Status=Enabled. Can be repeated.",
+				)
+				_ = cmd.RegisterFlagCompletionFunc("set", completeProperties(true))
+			},
+		},
+		{
+			cmd:       DeleteCmd,
+			providers: ProviderDeleteCmds,
+			services:  ServiceDeleteCmds,
+			resources: ResourceDeleteCmds,
+			run: func(cmd *cobra.Command, args []string) {
+				refs, ids, err := identifiers(cmd, args)
+				if err != nil {
+					crudl.ReportError(crudl.Invalid(err))
+					return
+				}
+				if cascade {
+					crudl.CascadeDeleteResources(cmd.Context(), cmd.Annotations["typeName"], refs, ids, noPrompts, async)
+					return
+				}
+				crudl.DeleteResources(cmd.Context(), cmd.Annotations["typeName"], refs, ids, noPrompts, async)
+			},
+			configure: func(cmd *cobra.Command) {
+				cmd.Args = requireIdentifiers
+				cmd.ValidArgsFunction = completeId
+				addIdPartsFlag(cmd)
+				addIdsFileFlag(cmd)
+				cmd.Flags().BoolVar(
+					&cascade,
+					"cascade",
+					false,
+					"also delete the live resources that depend on the resources being deleted, eg. a VPC's subnets",
+				)
+			},
+		},
+		{
+			cmd:       ListCmd,
+			providers: ProviderListCmds,
+			services:  ServiceListCmds,
+			resources: ResourceListCmds,
+			run: func(cmd *cobra.Command, args []string) {
+				crudl.ListResource(cmd.Context(), cmd.Annotations["typeName"], whereValues, output)
+			},
+			configure: func(cmd *cobra.Command) {
+				cmd.Flags().StringArrayVar(
+					&whereValues,
+					"where",
+					nil,
+					"only list resources with a matching property, eg. --where VpcId=vpc-1234. Can be repeated.",
+				)
+				_ = cmd.RegisterFlagCompletionFunc("where", completeProperties(false))
+			},
+		},
+		{
+			cmd:       ConfigureCmd,
+			providers: ProviderConfigureCmds,
+			services:  ServiceConfigureCmds,
+			resources: ResourceConfigureCmds,
+			include: func(entry data.IndexEntry) bool {
+				return entry.Configurable
+			},
+			run: func(cmd *cobra.Command, args []string) {
+				fmt.Println("TODO: implementation")
+			},
+		},
+		{
+			cmd:       GraphCmd,
+			providers: ProviderGraphCmds,
+			services:  ServiceGraphCmds,
+			resources: ResourceGraphCmds,
+			run: func(cmd *cobra.Command, args []string) {
+				refs, ids, err := identifiers(cmd, args)
+				if err != nil {
+					crudl.ReportError(crudl.Invalid(err))
+					return
+				}
+				ids = append(refs, ids...)
+				if len(ids) != 1 {
+					crudl.ReportError(crudl.Invalidf("graph command requires an identifier to be supplied as a single argument or with --id-part"))
+					return
+				}
+				crudl.Graph(cmd.Context(), cmd.Annotations["typeName"], ids[0], graphFormat, graphDepth, noPrompts)
+			},
+			configure: func(cmd *cobra.Command) {
+				cmd.ValidArgsFunction = completeId
+				addIdPartsFlag(cmd)
+				// long only, -f is --filename on the other verbs
+				cmd.Flags().StringVar(
+					&graphFormat,
+					"format",
+					"tree",
+					"output format, one of "+strings.Join(crudl.GraphFormats, ", "),
+				)
+				_ = cmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					return crudl.GraphFormats, cobra.ShellCompDirectiveNoFileComp
+				})
+				cmd.Flags().IntVar(&graphDepth, "depth", 2, "how many relationships away from the resource to follow")
+			},
+		},
+		{
+			cmd:       ExportCmd,
+			providers: ProviderExportCmds,
+			services:  ServiceExportCmds,
+			resources: ResourceExportCmds,
+			run: func(cmd *cobra.Command, args []string) {
+				refs, ids, err := identifiers(cmd, args)
+				if err != nil {
+					crudl.ReportError(crudl.Invalid(err))
+					return
+				}
+				ids = append(refs, ids...)
+				crudl.Export(cmd.Context(), cmd.Annotations["typeName"], ids, exportFormat, exportRelated, noPrompts)
+			},
+			configure: func(cmd *cobra.Command) {
+				cmd.Args = requireIdentifiers
+				cmd.ValidArgsFunction = completeId
+				addIdPartsFlag(cmd)
+				// long only, -f is --filename on the other verbs
+				cmd.Flags().StringVar(
+					&exportFormat,
+					"format",
+					"cfn",
+					"template format, one of "+strings.Join(crudl.ExportFormats, ", "),
+				)
+				_ = cmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+					return crudl.ExportFormats, cobra.ShellCompDirectiveNoFileComp
+				})
+				cmd.Flags().BoolVar(
+					&exportRelated,
+					"related",
+					false,
+					"also export the resources that the exported resources refer to, so that references between them are kept",
+				)
+			},
+		},
 	}
-	for provider, services := range *schemas {
-		updatable := data.IsUpdatable(services)
-		configurable := data.IsConfigurable(services)
-		provider = strings.ToLower(provider)
-		use, short, long := providers.GetCmdDetails(provider)
-		ProviderCreateCmds[provider] = &cobra.Command{Use: use, Short: short, Long: long}
-		ProviderReadCmds[provider] = &cobra.Command{Use: use, Short: short, Long: long}
-		if updatable {
-			ProviderUpdateCmds[provider] = &cobra.Command{Use: use, Short: short, Long: long}
+}
+
+// addIdPartsFlag adds --id-part, which builds composite identifiers from named parts instead of requiring them to be
+// joined with | in schema order.
+func addIdPartsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(
+		&idParts,
+		"id-part",
+		nil,
+		"supply part of a composite identifier by name, eg. --id-part RestApiId=abc123 --id-part StageName=prod. Can be repeated.",
+	)
+	_ = cmd.RegisterFlagCompletionFunc("id-part", completeIdParts)
+}
+
+// addIdsFileFlag adds --filename, which reads identifiers one per line from a file or stdin.
+func addIdsFileFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
+		&idsFile,
+		"filename",
+		"f",
+		"",
+		"read identifiers one per line from a file, - reads from stdin, eg. cloudctl list aws ec2 subnet -o name | cloudctl delete aws ec2 subnet -f -",
+	)
+}
+
+// requireIdentifiers validates that at least one identifier is supplied as an argument, with --id-part or with
+// --filename.
+func requireIdentifiers(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && len(idParts) == 0 && idsFile == "" {
+		return fmt.Errorf("requires at least 1 identifier as an argument, with --id-part or with --filename")
+	}
+	return nil
+}
+
+// identifiers returns args, which are references to resolve, and the identifiers built from --id-part or read from
+// --filename, which are used as they are so that bulk operations go straight to the worker pool.
+func identifiers(cmd *cobra.Command, args []string) ([]string, []string, error) {
+	var ids []string
+	if len(idParts) > 0 {
+		id, err := crudl.BuildIdentifier(cmd.Annotations["typeName"], idParts)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+	}
+	if idsFile != "" {
+		fromFile, err := crudl.ReadIdentifiers(idsFile)
+		if err != nil {
+			return nil, nil, err
 		}
-		ProviderDeleteCmds[provider] = &cobra.Command{Use: use, Short: short, Long: long}
-		ProviderListCmds[provider] = &cobra.Command{Use: use, Short: short, Long: long}
-		if configurable {
-			ProviderConfigureCmds[provider] = &cobra.Command{Use: use, Short: short, Long: long}
+		if len(fromFile) == 0 {
+			return nil, nil, fmt.Errorf("no identifiers were read from %s", idsFile)
 		}
+		ids = append(ids, fromFile...)
+	}
+	return args, ids, nil
+}
 
-		CreateCmd.AddCommand(ProviderCreateCmds[provider])
-		ReadCmd.AddCommand(ProviderReadCmds[provider])
-		if updatable {
-			UpdateCmd.AddCommand(ProviderUpdateCmds[provider])
+// requestedVerbs returns the verbs named in args. Only the trees for these verbs are built, so commands that don't
+// operate on resources, like --version or upgrade, don't pay the cost of loading the schema index.
+func requestedVerbs(args []string) []verb {
+	if completing(args) {
+		args = args[1:]
+	}
+	name := firstCommand(args)
+	// help list shows the list tree, so it is built as if list was run
+	if name == "help" {
+		for i, arg := range args {
+			if arg == "help" {
+				name = firstCommand(args[i+1:])
+				break
+			}
 		}
-		DeleteCmd.AddCommand(ProviderDeleteCmds[provider])
-		ListCmd.AddCommand(ProviderListCmds[provider])
-		if configurable {
-			ConfigureCmd.AddCommand(ProviderConfigureCmds[provider])
+	}
+	for _, v := range verbs() {
+		if name == v.cmd.Name() {
+			return []verb{v}
 		}
+	}
+	return nil
+}
 
-		ServiceCreateCmds[provider] = map[string]*cobra.Command{}
-		ServiceReadCmds[provider] = map[string]*cobra.Command{}
-		if updatable {
-			ServiceUpdateCmds[provider] = map[string]*cobra.Command{}
+// firstCommand returns the first argument that is not a global flag or a global flag's value, so that a verb named
+// as a subcommand, like schemas export, is not mistaken for the export verb.
+func firstCommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return arg
 		}
-		ServiceDeleteCmds[provider] = map[string]*cobra.Command{}
-		ServiceListCmds[provider] = map[string]*cobra.Command{}
-		if configurable {
-			ServiceConfigureCmds[provider] = map[string]*cobra.Command{}
+		if strings.Contains(arg, "=") {
+			continue
 		}
-
-		ResourceCreateCmds[provider] = map[string]map[string]*cobra.Command{}
-		ResourceReadCmds[provider] = map[string]map[string]*cobra.Command{}
-		if updatable {
-			ResourceUpdateCmds[provider] = map[string]map[string]*cobra.Command{}
+		name := strings.TrimLeft(arg, "-")
+		flag := RootCmd.PersistentFlags().Lookup(name)
+		if flag == nil && len(name) == 1 {
+			flag = RootCmd.PersistentFlags().ShorthandLookup(name)
 		}
-		ResourceDeleteCmds[provider] = map[string]map[string]*cobra.Command{}
-		ResourceListCmds[provider] = map[string]map[string]*cobra.Command{}
-		if configurable {
-			ResourceConfigureCmds[provider] = map[string]map[string]*cobra.Command{}
+		if flag != nil && flag.Value.Type() != "bool" {
+			i++
 		}
+	}
+	return ""
+}
 
-		for service, resources := range services {
-			service = strings.ToLower(service)
-			updatable := data.IsUpdatable(services)
-			configurable := data.IsConfigurable(services)
-			use, short, long := providers.GetCmdDetails(service)
-			ServiceCreateCmds[provider][service] = &cobra.Command{Use: use, Short: short, Long: long}
-			ServiceReadCmds[provider][service] = &cobra.Command{Use: use, Short: short, Long: long}
-			if updatable {
-				ServiceUpdateCmds[provider][service] = &cobra.Command{Use: use, Short: short, Long: long}
-			}
-			ServiceDeleteCmds[provider][service] = &cobra.Command{Use: use, Short: short, Long: long}
-			ServiceListCmds[provider][service] = &cobra.Command{Use: use, Short: short, Long: long}
-			if configurable {
-				ServiceConfigureCmds[provider][service] = &cobra.Command{Use: use, Short: short, Long: long}
-			}
-
-			ProviderCreateCmds[provider].AddCommand(ServiceCreateCmds[provider][service])
-			ProviderReadCmds[provider].AddCommand(ServiceReadCmds[provider][service])
-			if updatable {
-				ProviderUpdateCmds[provider].AddCommand(ServiceUpdateCmds[provider][service])
-			}
-			ProviderDeleteCmds[provider].AddCommand(ServiceDeleteCmds[provider][service])
-			ProviderListCmds[provider].AddCommand(ServiceListCmds[provider][service])
-			if configurable {
-				ProviderConfigureCmds[provider].AddCommand(ServiceConfigureCmds[provider][service])
-			}
+// initResourceCommands builds the provider, service and resource commands for requested from the schema index. Schemas
+// are not loaded here, resource commands load the schema for their type when they are run. Every verb shows its help
+// when run on its own, cobra only lists commands that are runnable or have subcommands, so verbs whose trees aren't
+// built are still listed in the root command's help.
+func initResourceCommands(requested []verb) {
+	for _, v := range verbs() {
+		v.cmd.Args = cobra.NoArgs
+		v.cmd.Run = func(cmd *cobra.Command, args []string) {
+			_ = cmd.Help()
+		}
+	}
+	if len(requested) == 0 {
+		return
+	}
+	index, err := data.GetIndex()
+	if err != nil {
+		crudl.ReportError(err)
+		return
+	}
+	for _, v := range requested {
+		v.build(*index)
+	}
+}
 
-			ResourceCreateCmds[provider][service] = map[string]*cobra.Command{}
-			ResourceReadCmds[provider][service] = map[string]*cobra.Command{}
-			if updatable {
-				ResourceUpdateCmds[provider][service] = map[string]*cobra.Command{}
-			}
-			ResourceDeleteCmds[provider][service] = map[string]*cobra.Command{}
-			ResourceListCmds[provider][service] = map[string]*cobra.Command{}
-			if configurable {
-				ResourceConfigureCmds[provider][service] = map[string]*cobra.Command{}
-			}
+func (v verb) included(entry data.IndexEntry) bool {
+	return v.include == nil || v.include(entry)
+}
 
-			for resource, schema := range resources {
-				resource = strings.ToLower(resource)
-				updatable := data.IsUpdatable(services)
-				configurable := data.IsConfigurable(services)
-				short = schema.Description
-				long = schema.DocumentationUrl
-				ResourceCreateCmds[provider][service][resource] = &cobra.Command{
-					Use: resource,
-					Annotations: map[string]string{
-						"typeName": schema.TypeName,
-						"resource": resource,
-						"service":  service,
-						"provider": provider,
-					},
-					Short: short,
-					Long:  long,
-					Run: func(cmd *cobra.Command, args []string) {
-						CreateEdit(cmd.Annotations["typeName"])
-					},
-				}
-				ResourceReadCmds[provider][service][resource] = &cobra.Command{
-					Use: resource,
-					Annotations: map[string]string{
-						"typeName": schema.TypeName,
-						"resource": resource,
-						"service":  service,
-						"provider": provider,
-					},
-					Short: short,
-					Long:  long,
-					Run: func(cmd *cobra.Command, args []string) {
-						if len(args) != 1 {
-							fmt.Println("read command requires an identifier to be supplied as a single argument")
-						}
-						crudl.ReadResource(cmd.Annotations["typeName"], args[0], noPrompts, async)
-					},
-					ValidArgsFunction: completeId,
-				}
-				if updatable {
-					ResourceUpdateCmds[provider][service][resource] = &cobra.Command{
-						Use: resource,
-						Annotations: map[string]string{
-							"typeName": schema.TypeName,
-							"resource": resource,
-							"service":  service,
-							"provider": provider,
-						},
-						Short: short,
-						Long:  long,
-						Run: func(cmd *cobra.Command, args []string) {
-							fmt.Println("TODO: implementation")
-						},
-					}
-				}
-				ResourceDeleteCmds[provider][service][resource] = &cobra.Command{
-					Use: resource,
-					Annotations: map[string]string{
-						"typeName": schema.TypeName,
-						"resource": resource,
-						"service":  service,
-						"provider": provider,
-					},
-					Short: short,
-					Long:  long,
-					Args:  cobra.MinimumNArgs(1),
-					Run: func(cmd *cobra.Command, args []string) {
-						crudl.DeleteResources(cmd.Annotations["typeName"], args, noPrompts, async)
-					},
-					ValidArgsFunction: completeId,
-				}
-				ResourceListCmds[provider][service][resource] = &cobra.Command{
-					Use: resource,
-					Annotations: map[string]string{
-						"typeName": schema.TypeName,
-						"resource": resource,
-						"service":  service,
-						"provider": provider,
-					},
-					Short: short,
-					Long:  long,
-					Run: func(cmd *cobra.Command, args []string) {
-						crudl.ListResource(cmd.Annotations["typeName"])
-					},
-				}
-				if configurable {
-					ResourceConfigureCmds[provider][service][resource] = &cobra.Command{
-						Use: resource,
-						Annotations: map[string]string{
-							"typeName": schema.TypeName,
-							"resource": resource,
-							"service":  service,
-							"provider": provider,
-						},
-						Short: short,
-						Long:  long,
-						Run: func(cmd *cobra.Command, args []string) {
-							fmt.Println("TODO: implementation")
-						},
-					}
+func (v verb) build(index data.Index) {
+	for provider, services := range index {
+		var providerCmd *cobra.Command
+		for service, resources := range services {
+			var serviceCmd *cobra.Command
+			for resource, entry := range resources {
+				if !v.included(entry) {
+					continue
 				}
-
-				ServiceCreateCmds[provider][service].AddCommand(ResourceCreateCmds[provider][service][resource])
-				ServiceReadCmds[provider][service].AddCommand(ResourceReadCmds[provider][service][resource])
-				if updatable {
-					ServiceUpdateCmds[provider][service].AddCommand(ResourceUpdateCmds[provider][service][resource])
+				if providerCmd == nil {
+					providerCmd = v.providerCmd(provider)
 				}
-				ServiceDeleteCmds[provider][service].AddCommand(ResourceDeleteCmds[provider][service][resource])
-				ServiceListCmds[provider][service].AddCommand(ResourceListCmds[provider][service][resource])
-				if configurable {
-					ServiceConfigureCmds[provider][service].AddCommand(ResourceConfigureCmds[provider][service][resource])
+				if serviceCmd == nil {
+					serviceCmd = v.serviceCmd(providerCmd, provider, service)
 				}
+				v.resourceCmd(serviceCmd, provider, service, resource, entry)
 			}
 		}
 	}
 }
 
-func completeId(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	// this was in the example from cobra docs, but it's not clear whether it's needed
-	//if len(args) != 0 {
-	//	return nil, cobra.ShellCompDirectiveNoFileComp
-	//}
-	fmt.Println(cmd.Parent().Parent().Parent().Name())
-	resources, err := awsProvider.ListResource(cmd.Annotations["typeName"])
-	if err != nil {
-		fmt.Printf("ERROR: %q\n", err.Error())
-		return nil, cobra.ShellCompDirectiveNoFileComp
+func (v verb) providerCmd(provider string) *cobra.Command {
+	provider = strings.ToLower(provider)
+	use, short, long := providers.GetCmdDetails(provider)
+	v.providers[provider] = &cobra.Command{Use: use, Short: short, Long: long}
+	v.services[provider] = map[string]*cobra.Command{}
+	v.resources[provider] = map[string]map[string]*cobra.Command{}
+	v.cmd.AddCommand(v.providers[provider])
+	return v.providers[provider]
+}
+
+func (v verb) serviceCmd(providerCmd *cobra.Command, provider string, service string) *cobra.Command {
+	provider = strings.ToLower(provider)
+	service = strings.ToLower(service)
+	use, short, long := providers.GetCmdDetails(service)
+	v.services[provider][service] = &cobra.Command{Use: use, Short: short, Long: long}
+	v.resources[provider][service] = map[string]*cobra.Command{}
+	providerCmd.AddCommand(v.services[provider][service])
+	return v.services[provider][service]
+}
+
+func (v verb) resourceCmd(serviceCmd *cobra.Command, provider string, service string, resource string, entry data.IndexEntry) {
+	provider = strings.ToLower(provider)
+	service = strings.ToLower(service)
+	resource = strings.ToLower(resource)
+	cmd := &cobra.Command{
+		Use: resource,
+		Annotations: map[string]string{
+			"typeName": entry.TypeName,
+			"resource": resource,
+			"service":  service,
+			"provider": provider,
+		},
+		Short: entry.Description,
+		Long:  entry.DocumentationUrl,
+		Run:   v.run,
 	}
-	headers := append([]interface{}{"Identifier"}, crudl.GetTableHeaders(*resources)...)
-	var completeList []string
-	for _, r := range *resources {
-		row := crudl.GetRow(r, headers)
-		completeStr := row[0].(string) + "\t"
-		for _, i := range row[1:] {
-			completeStr = completeStr + i.(string) + " "
-		}
-		completeList = append(completeList, completeStr)
+	if v.configure != nil {
+		v.configure(cmd)
 	}
-	return completeList, cobra.ShellCompDirectiveNoFileComp
+	v.resources[provider][service][resource] = cmd
+	serviceCmd.AddCommand(cmd)
 }
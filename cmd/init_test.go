@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jaymccon/cloudctl/data"
+	"github.com/spf13/cobra"
+	"testing"
+)
+
+// benchmarkIndex is an index about the size of the public registry, 1000 types across 200 services.
+func benchmarkIndex() data.Index {
+	index := data.Index{"AWS": {}}
+	for s := 0; s < 200; s++ {
+		service := fmt.Sprintf("Service%d", s)
+		index["AWS"][service] = map[string]data.IndexEntry{}
+		for r := 0; r < 5; r++ {
+			resource := fmt.Sprintf("Resource%d", r)
+			index["AWS"][service][resource] = data.IndexEntry{
+				TypeName:     fmt.Sprintf("AWS::%s::%s", service, resource),
+				Description:  "A resource type used to benchmark building the command tree.",
+				Updatable:    true,
+				Configurable: r == 0,
+			}
+		}
+	}
+	return index
+}
+
+// freshVerb returns a copy of v that builds into new commands, so that each benchmark iteration starts from an empty
+// tree.
+func freshVerb(v verb) verb {
+	v.cmd = &cobra.Command{Use: v.cmd.Use, Short: v.cmd.Short}
+	v.providers = map[string]*cobra.Command{}
+	v.services = map[string]map[string]*cobra.Command{}
+	v.resources = map[string]map[string]map[string]*cobra.Command{}
+	return v
+}
+
+// BenchmarkBuildCommandTree measures building a verb's command tree from the index, which is the startup cost of a
+// resource command. Only the requested verb is built, so each verb is measured on its own.
+func BenchmarkBuildCommandTree(b *testing.B) {
+	index := benchmarkIndex()
+	for _, v := range verbs() {
+		v := v
+		b.Run(v.cmd.Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				freshVerb(v).build(index)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/jaymccon/cloudctl/crudl"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// cancelTimeout bounds the calls that cancel pending requests, which are made after the command's context is done
+	cancelTimeout = 30 * time.Second
+	// interruptedExitCode is the conventional exit code of a process stopped by SIGINT
+	interruptedExitCode = 130
+)
+
+// commandContext returns the context commands run with. It is cancelled by the first Ctrl-C, so that operations stop
+// waiting for cloud control, and expires after --timeout if one is set. A second Ctrl-C exits immediately.
+func commandContext() (context.Context, context.CancelFunc, func() bool) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	var interrupted int32
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		for range signals {
+			if !atomic.CompareAndSwapInt32(&interrupted, 0, 1) {
+				os.Exit(interruptedExitCode)
+			}
+			fmt.Fprintln(os.Stderr, "\nInterrupted, stopping. Press Ctrl-C again to exit immediately.")
+			cancel()
+		}
+	}()
+	return ctx, cancel, func() bool { return atomic.LoadInt32(&interrupted) == 1 }
+}
+
+// handlePendingRequests deals with requests that were still in progress when the command stopped waiting for them.
+// After a Ctrl-C they can be cancelled, otherwise their tokens are printed so that they can be followed up.
+func handlePendingRequests(interrupted bool) {
+	requests := awsProvider.PendingRequests()
+	if len(requests) == 0 {
+		return
+	}
+	cancelRequests := false
+	if interrupted && !noPrompts {
+		// an answer that can't be read leaves the requests running, they are listed below
+		cancelRequests, _ = crudl.Confirm(fmt.Sprintf("Cancel %d pending request(s)?", len(requests)))
+	}
+	if cancelRequests {
+		ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+		defer cancel()
+		for _, r := range requests {
+			err := awsProvider.CancelRequest(ctx, r.Token)
+			if err != nil {
+				crudl.ReportError(fmt.Errorf("cancelling %s of %s %q: %w", r.Verb, r.TypeName, r.Identifier, err))
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Cancelled %s of %s %q\n", r.Verb, r.TypeName, r.Identifier)
+		}
+		requests = awsProvider.PendingRequests()
+		if len(requests) == 0 {
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, "The following requests are still in progress:")
+	for _, r := range requests {
+		fmt.Fprintf(os.Stderr, "  %s %s %q request token: %s\n", r.Verb, r.TypeName, r.Identifier, r.Token)
+	}
+	fmt.Fprintln(os.Stderr, "Check their status with: aws cloudcontrol get-resource-request-status --request-token <token>")
+}
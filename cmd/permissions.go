@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"github.com/jaymccon/cloudctl/crudl"
+	"github.com/jaymccon/cloudctl/data"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+)
+
+var permissionTypes []string
+var permissionVerbs []string
+var permissionManifests string
+var permissionScope bool
+
+var PermissionsCmd = &cobra.Command{
+	Use:   "permissions",
+	Short: "prints a least-privilege IAM policy for managing resources",
+	Long: `permissions prints an IAM policy document that allows the given verbs on the given resource types, or what
+applying a set of manifests needs. The policy unions the permissions listed by the handlers in the cached schemas with
+the Cloud Control API actions that cloudctl calls.
+
+With --scope the handler actions are limited to the resources in the manifests, for types where every manifest has an
+arn as its identifier or Arn property. Handlers often need actions on related resources too, so scoped policies should
+be tested before they are relied on.`,
+	Example: `  cloudctl permissions --type AWS::S3::Bucket --type AWS::SQS::Queue --verb create,read,delete
+  cloudctl permissions -f manifests/ --scope`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		bootstrapCache(cmd.Context(), os.Args[1:])
+		crudl.Permissions(permissionTypes, permissionVerbs, permissionManifests, permissionScope)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(PermissionsCmd)
+
+	flags := PermissionsCmd.Flags()
+	flags.StringArrayVarP(
+		&permissionTypes,
+		"type",
+		"t",
+		nil,
+		"resource type to allow, eg. AWS::S3::Bucket. Can be repeated.",
+	)
+	flags.StringSliceVar(
+		&permissionVerbs,
+		"verb",
+		nil,
+		"verbs to allow on the types, any of "+strings.Join(crudl.PolicyVerbs, ", ")+" (default all)",
+	)
+	flags.StringVarP(
+		&permissionManifests,
+		"filename",
+		"f",
+		"",
+		"manifest file or directory of manifests to allow applying, - reads manifests from stdin",
+	)
+	flags.BoolVar(
+		&permissionScope,
+		"scope",
+		false,
+		"limit handler actions to the arns of the manifests' resources where they are known",
+	)
+	_ = PermissionsCmd.RegisterFlagCompletionFunc("type", completeTypeNames)
+	_ = PermissionsCmd.RegisterFlagCompletionFunc("verb", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return crudl.PolicyVerbs, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// completeTypeNames completes the type names of the cached schemas.
+func completeTypeNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	index, err := data.GetIndex()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var typeNames []string
+	for _, services := range *index {
+		for _, resources := range services {
+			for _, entry := range resources {
+				if strings.HasPrefix(entry.TypeName, toComplete) {
+					typeNames = append(typeNames, entry.TypeName)
+				}
+			}
+		}
+	}
+	sort.Strings(typeNames)
+	return typeNames, cobra.ShellCompDirectiveNoFileComp
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"github.com/jaymccon/cloudctl/crudl"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
 	"github.com/spf13/cobra"
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,6 +19,8 @@ const (
 var cfgFile string
 var noPrompts bool
 var async bool
+var output string
+var timeout time.Duration
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -29,8 +35,32 @@ constantly be flipping between documentation and google searches to know how to
 follows the verb-noun adjective pattern popularised by modern cli's like kubectl`,
 }
 
+// Execute runs the command and exits with the code of the first error that was reported, see crudl.ExitCode.
 func Execute() {
-	cobra.CheckErr(RootCmd.Execute())
+	// the timeout and error format apply to the whole command, so they are needed before cobra parses the flags
+	parseGlobalFlags(os.Args[1:])
+	crudl.JsonErrors = output == "json"
+	awsProvider.JsonOutput = output == "json"
+	ctx, cancel, interrupted := commandContext()
+	verbs := requestedVerbs(os.Args[1:])
+	if len(verbs) > 0 {
+		bootstrapCache(ctx, os.Args[1:])
+	}
+	initResourceCommands(verbs)
+	err := RootCmd.ExecuteContext(ctx)
+	if ctx.Err() == context.DeadlineExceeded {
+		crudl.ReportError(fmt.Errorf("timed out after %s", timeout))
+	}
+	cancel()
+	handlePendingRequests(interrupted())
+	if interrupted() {
+		os.Exit(interruptedExitCode)
+	}
+	if err != nil {
+		// cobra has already printed the usage error
+		os.Exit(crudl.ExitValidation)
+	}
+	os.Exit(crudl.ExitCode())
 }
 
 func init() {
@@ -59,6 +89,67 @@ func init() {
 		false,
 		"Return with a request id immediately for operations that are async and do not block for resource stabilization,",
 	)
+	flags.StringVarP(
+		&output,
+		"output",
+		"o",
+		"",
+		"output format, list supports table, name and json, read supports yaml and json, and json prints the results of create, update and delete as json",
+	)
+	flags.DurationVar(
+		&timeout,
+		"timeout",
+		0,
+		"stop waiting for operations after this long, eg. 10m. Requests that are still in progress are listed with their request tokens",
+	)
+	flags.Bool(
+		"check-permissions",
+		false,
+		"simulate the IAM permissions that create, update and delete need before making any changes, and stop if any are missing",
+	)
+	_ = viper.BindPFlag("check_permissions", flags.Lookup("check-permissions"))
+	flags.Int(
+		"parallelism",
+		awsProvider.Parallelism,
+		"number of concurrent AWS calls made by bulk operations",
+	)
+	_ = viper.BindPFlag("parallelism", flags.Lookup("parallelism"))
+	flags.String(
+		"role-arn",
+		"",
+		"arn of a role to assume for AWS calls, overrides role_arn and mutating_role_arn in the config file",
+	)
+	_ = viper.BindPFlag("role_arn", flags.Lookup("role-arn"))
+	flags.String(
+		"external-id",
+		"",
+		"external id to pass when assuming the role",
+	)
+	_ = viper.BindPFlag("external_id", flags.Lookup("external-id"))
+	flags.String(
+		"mfa-serial",
+		"",
+		"serial number or arn of the MFA device the role requires, the code is prompted for once and cached until the session expires",
+	)
+	_ = viper.BindPFlag("mfa_serial", flags.Lookup("mfa-serial"))
+	flags.Duration(
+		"session-duration",
+		0,
+		"duration of the role session, eg. 1h (default the role's STS default)",
+	)
+	_ = viper.BindPFlag("session_duration", flags.Lookup("session-duration"))
+	flags.String(
+		"service-role",
+		"",
+		"arn of a role for Cloud Control to run resource handlers as, overrides service_role in the config file",
+	)
+	_ = viper.BindPFlag("service_role", flags.Lookup("service-role"))
+	flags.String(
+		"context",
+		"",
+		"name of a context in the config file's contexts, its settings are used over the top level ones",
+	)
+	_ = viper.BindPFlag("context", flags.Lookup("context"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -86,4 +177,31 @@ func initConfig() {
 			panic(err)
 		}
 	}
+
+	// a context's settings replace the top level ones, eg. so each account can have its own roles
+	if name := viper.GetString("context"); name != "" {
+		settings := viper.GetStringMap("contexts." + name)
+		if len(settings) == 0 {
+			crudl.ReportError(crudl.Invalidf("context %q is not defined in the config file", name))
+			os.Exit(crudl.ExitCode())
+		}
+		cobra.CheckErr(viper.MergeConfigMap(settings))
+	}
+
+	crudl.CheckPermissions = viper.GetBool("check_permissions")
+	if p := viper.GetInt("parallelism"); p > 0 {
+		awsProvider.Parallelism = p
+	}
+	if viper.IsSet("list_cache_ttl") {
+		crudl.ListCacheTTL = viper.GetDuration("list_cache_ttl")
+	}
+	// protection rules replace the defaults, so that they can also be relaxed
+	if viper.IsSet("protected_types") {
+		crudl.ProtectedTypes = viper.GetStringSlice("protected_types")
+	}
+	if viper.IsSet("protected_tags") {
+		crudl.ProtectedTags = viper.GetStringMapString("protected_tags")
+	}
+	configureRole()
+	awsProvider.ServiceRoleArn = viper.GetString("service_role")
 }
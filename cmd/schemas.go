@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jaymccon/cloudctl/crudl"
+	"github.com/jaymccon/cloudctl/data"
+
+	"github.com/spf13/cobra"
+)
+
+var exportTypes []string
+
+var SchemasCmd = &cobra.Command{
+	Use:   "schemas",
+	Short: "manages the local schema cache",
+}
+
+var SchemasExportCmd = &cobra.Command{
+	Use:   "export <bundle.tar.gz>",
+	Short: "exports cached schemas to a bundle",
+	Long: `export packs the cached schema files into a gzipped tarball with a manifest and checksums. The bundle can be 
+imported on machines that cannot reach the CloudFormation registry.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		count, err := data.ExportSchemas(args[0], exportTypes)
+		if err != nil {
+			crudl.ReportError(err)
+			return
+		}
+		fmt.Printf("Exported %d schemas to %s\n", count, args[0])
+	},
+}
+
+var SchemasImportCmd = &cobra.Command{
+	Use:   "import <bundle.tar.gz|directory>",
+	Short: "imports schemas from a bundle or a directory",
+	Long: `import adds schemas from a bundle created with "cloudctl schemas export" to the cache. A directory of schema 
+json files can be imported instead of a bundle, types imported this way are kept when running "cloudctl upgrade" until
+they are upgraded explicitly with --type.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		count, err := data.ImportSchemas(args[0])
+		if err != nil {
+			crudl.ReportError(err)
+			return
+		}
+		fmt.Printf("Imported %d schemas from %s\n", count, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(SchemasCmd)
+	SchemasCmd.AddCommand(SchemasExportCmd)
+	SchemasCmd.AddCommand(SchemasImportCmd)
+
+	SchemasExportCmd.Flags().StringSliceVarP(
+		&exportTypes,
+		"type",
+		"t",
+		nil,
+		"only export the given resource types, eg. AWS::S3::Bucket. Can be repeated or comma separated.",
+	)
+}
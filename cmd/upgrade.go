@@ -1,22 +1,54 @@
 package cmd
 
 import (
+	"github.com/jaymccon/cloudctl/crudl"
 	"github.com/jaymccon/cloudctl/data"
 
 	"github.com/spf13/cobra"
 )
 
+var upgradeTypes []string
+var upgradeVersion string
+var upgradeFull bool
+
 var UpgradeCmd = &cobra.Command{
 	Use:   "upgrade",
 	Short: "upgrade cloudctl resource definitions",
+	Long: `upgrade downloads resource schemas from the CloudFormation registry into the local cache. Only types that have 
+changed since the last upgrade are downloaded, use --type to refresh specific types or --full to download everything.
+
+Private types registered in, and third party types activated in, the current account and region are included. A 
+non-default version of a private type can be cached with --type-version, the type then stays on that version until it
+is upgraded again with --type.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := data.UpdateCache()
+		err := data.UpdateCache(cmd.Context(), upgradeTypes, upgradeVersion, upgradeFull)
 		if err != nil {
-			cmd.PrintErrf("ERROR: %q\n", err.Error())
+			crudl.ReportError(err)
 		}
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(UpgradeCmd)
+
+	flags := UpgradeCmd.Flags()
+	flags.StringSliceVarP(
+		&upgradeTypes,
+		"type",
+		"t",
+		nil,
+		"only refresh the given resource types, eg. AWS::S3::Bucket. Can be repeated or comma separated.",
+	)
+	flags.StringVar(
+		&upgradeVersion,
+		"type-version",
+		"",
+		"cache a specific version of a private type instead of its default version, requires a single --type",
+	)
+	flags.BoolVar(
+		&upgradeFull,
+		"full",
+		false,
+		"ignore cached versions and download every resource type",
+	)
 }
@@ -0,0 +1,324 @@
+package crudl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"strings"
+)
+
+// appliedResource is a manifest's resource once it exists, used to resolve references from later manifests.
+type appliedResource struct {
+	identifier string
+	properties map[string]interface{}
+}
+
+// Apply creates the resources of manifests without an identifier and updates the ones with an identifier. Manifests are
+// applied in dependency order, waiting for each to complete, so that references between them can be resolved.
+// Properties that aren't in a manifest are left as they are when updating.
+func Apply(ctx context.Context, path string, noPrompts bool) {
+	if path == "-" {
+		stdinConsumed = true
+	}
+	manifests, err := data.ReadManifests(path)
+	if err != nil {
+		ReportError(Invalid(err))
+		return
+	}
+	ordered, err := validateManifests(manifests)
+	if err != nil {
+		ReportError(Invalid(err))
+		return
+	}
+	var checks []permissionCheck
+	for _, m := range ordered {
+		// manifests without an identifier are updated instead when the resource already exists
+		if m.Identifier == "" {
+			checks = append(checks, permissionCheck{"create", m.TypeName})
+		}
+		checks = append(checks, permissionCheck{"update", m.TypeName})
+	}
+	if err := preflight(ctx, checks...); err != nil {
+		ReportError(err)
+		return
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	if noPrompts {
+		fmt.Println("Apply plan:")
+		for _, m := range ordered {
+			if m.Identifier == "" {
+				fmt.Printf("  create %s %s\n", m.TypeName, m.Name)
+			} else {
+				fmt.Printf("  update %s %s (%s)\n", m.TypeName, m.Name, m.Identifier)
+			}
+		}
+	} else {
+		if err := printPlan(ctx, cc, ordered); err != nil {
+			ReportError(err)
+			return
+		}
+		if !confirmed(fmt.Sprintf("Are you sure you want to apply these %d manifests", len(ordered))) {
+			fmt.Println("Exiting without applying anything.")
+			return
+		}
+	}
+	applied := map[string]appliedResource{}
+	resolver := &intrinsicResolver{
+		resources: map[string]bool{},
+		attribute: func(name string, attr string) (interface{}, bool) {
+			r, ok := applied[name]
+			if !ok {
+				return nil, false
+			}
+			if attr == "" {
+				return r.identifier, true
+			}
+			return data.GetPath(r.properties, attr)
+		},
+	}
+	for _, m := range ordered {
+		resolver.resources[m.Name] = true
+	}
+	for i, m := range ordered {
+		resolver.problems = nil
+		desired, res := resolver.resolve("", m.Properties)
+		if res != resolved {
+			ReportError(Invalidf("%s could not be resolved: %s", m.Name, strings.Join(resolver.problems, ", ")))
+			printNotApplied(ordered[i:])
+			return
+		}
+		id, err := applyManifest(ctx, cc, m, desired.(map[string]interface{}))
+		if err != nil {
+			ReportError(fmt.Errorf("applying %s: %w", m.Name, err))
+			printNotApplied(ordered[i:])
+			return
+		}
+		props, err := awsProvider.GetResource(ctx, cc, m.TypeName, id)
+		current := map[string]interface{}{}
+		if err == nil {
+			_ = json.Unmarshal([]byte(*props), &current)
+		}
+		applied[m.Name] = appliedResource{identifier: id, properties: current}
+	}
+	fmt.Println("Applied manifests, add their identifiers to update them with the next apply:")
+	for _, m := range ordered {
+		fmt.Printf("  %s identifier: %s\n", m.Name, applied[m.Name].identifier)
+	}
+}
+
+func applyManifest(ctx context.Context, cc *cloudcontrol.Client, m data.Manifest, desired map[string]interface{}) (string, error) {
+	if m.Identifier == "" {
+		schema, err := data.GetSchema(m.TypeName)
+		if err != nil {
+			return "", err
+		}
+		id, exists := existingIdentifier(ctx, cc, *schema, desired)
+		if !exists {
+			return createManifest(ctx, cc, m, desired)
+		}
+		fmt.Printf("%s already exists as %s %q, updating it instead\n", m.Name, m.TypeName, id)
+		m.Identifier = id
+	}
+	patch, err := manifestPatch(ctx, cc, m, desired)
+	if err != nil {
+		return "", err
+	}
+	if len(patch) == 0 {
+		fmt.Printf("%s %s is up to date\n", m.TypeName, m.Identifier)
+		return m.Identifier, nil
+	}
+	patchDoc, err := json.Marshal(patch)
+	if err != nil {
+		return "", err
+	}
+	pd := string(patchDoc)
+	_, err = awsProvider.UpdateResource(ctx, cc, m.TypeName, m.Identifier, &pd, false)
+	return m.Identifier, err
+}
+
+// manifestPatch returns the patch that updates the manifest's resource to desired. Properties that aren't in the
+// manifest are left as they are, and changes to create only properties are rejected.
+func manifestPatch(ctx context.Context, cc *cloudcontrol.Client, m data.Manifest, desired map[string]interface{}) ([]patchOperation, error) {
+	schema, err := data.GetSchema(m.TypeName)
+	if err != nil {
+		return nil, err
+	}
+	props, err := awsProvider.GetResource(ctx, cc, m.TypeName, m.Identifier)
+	if err != nil {
+		return nil, resourceError(err, m.TypeName, m.Identifier)
+	}
+	current := map[string]interface{}{}
+	err = json.Unmarshal([]byte(*props), &current)
+	if err != nil {
+		return nil, err
+	}
+	removeReadOnly(*schema, current)
+	var patch []patchOperation
+	for _, op := range diffProperties(current, desired) {
+		if op.Op != "remove" {
+			patch = append(patch, op)
+		}
+	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	// the patch replaces the manifest's top level properties and leaves the others as they are
+	patched := map[string]interface{}{}
+	for k, v := range current {
+		patched[k] = v
+	}
+	for k, v := range desired {
+		patched[k] = v
+	}
+	if path, ok := changedCreateOnly(*schema, current, patched); ok {
+		return nil, Invalidf("%s: %s cannot be changed after %s resources are created", m.Name, path, m.TypeName)
+	}
+	return patch, nil
+}
+
+// printPlan prints what applying each manifest will do, the properties of the resources that will be created and the
+// patches of the ones that will be updated. References to other manifests are shown as they are written, they are
+// resolved as the manifests are applied.
+func printPlan(ctx context.Context, cc *cloudcontrol.Client, manifests []data.Manifest) error {
+	// a resolver without attributes defers references to other manifests
+	planner := &intrinsicResolver{resources: map[string]bool{}}
+	for _, m := range manifests {
+		planner.resources[m.Name] = true
+	}
+	fmt.Println("Apply plan:")
+	for _, m := range manifests {
+		planner.problems = nil
+		desired := m.Properties
+		if v, _ := planner.resolve("", m.Properties); v != nil {
+			if resolvedProps, ok := v.(map[string]interface{}); ok {
+				desired = resolvedProps
+			}
+		}
+		if m.Identifier == "" {
+			schema, err := data.GetSchema(m.TypeName)
+			if err != nil {
+				return err
+			}
+			id, exists := existingIdentifier(ctx, cc, *schema, desired)
+			if !exists {
+				props, err := indentedYaml(desired, "    ")
+				if err != nil {
+					return err
+				}
+				fmt.Printf("  create %s %s with the properties:\n%s", m.TypeName, m.Name, props)
+				continue
+			}
+			fmt.Printf("  %s already exists as %s %q, it will be updated instead\n", m.Name, m.TypeName, id)
+			m.Identifier = id
+		}
+		patch, err := manifestPatch(ctx, cc, m, desired)
+		if err != nil {
+			return err
+		}
+		if len(patch) == 0 {
+			fmt.Printf("  %s %s (%s) is up to date\n", m.TypeName, m.Name, m.Identifier)
+			continue
+		}
+		b, err := json.MarshalIndent(patch, "    ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  update %s %s (%s) with the patch:\n    %s\n", m.TypeName, m.Name, m.Identifier, string(b))
+	}
+	return nil
+}
+
+func printNotApplied(manifests []data.Manifest) {
+	var names []string
+	for _, m := range manifests {
+		names = append(names, m.Name)
+	}
+	fmt.Printf("Stopped, these manifests were not applied: %s\n", strings.Join(names, ", "))
+}
+
+// validateManifests checks that manifests can be applied and returns them in dependency order.
+func validateManifests(manifests []data.Manifest) ([]data.Manifest, error) {
+	byName := map[string]data.Manifest{}
+	names := map[string]bool{}
+	var problems []string
+	for _, m := range manifests {
+		if names[m.Name] {
+			problems = append(problems, fmt.Sprintf("%s: more than one manifest has this name", m.Name))
+		}
+		names[m.Name] = true
+		byName[m.Name] = m
+		for _, u := range m.Unresolved {
+			problems = append(problems, fmt.Sprintf("%s: unresolved %s", m.Name, u))
+		}
+		schema, err := data.GetSchema(m.TypeName)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", m.Name, err.Error()))
+			continue
+		}
+		for _, p := range schema.UnknownProperties(m.Properties) {
+			problems = append(problems, fmt.Sprintf("%s: %s does not have a property %q", m.Name, m.TypeName, p))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("manifests can't be applied:\n  %s", strings.Join(problems, "\n  "))
+	}
+	var ordered []data.Manifest
+	state := map[string]int{}
+	var visit func(name string, chain []string) error
+	visit = func(name string, chain []string) error {
+		m, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("%s depends on %q which is not a manifest", chain[len(chain)-1], name)
+		}
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("manifests depend on each other: %s", strings.Join(append(chain, name), " -> "))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, dep := range append(m.DependsOn, references(m.Properties, names)...) {
+			if err := visit(dep, append(chain, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		ordered = append(ordered, m)
+		return nil
+	}
+	for _, m := range manifests {
+		if err := visit(m.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// createManifest creates the manifest's resource with a client token derived from its desired state, so that applying
+// the manifest again after an interrupted apply doesn't create a duplicate.
+func createManifest(ctx context.Context, cc *cloudcontrol.Client, m data.Manifest, desired map[string]interface{}) (string, error) {
+	desiredState, err := json.Marshal(desired)
+	if err != nil {
+		return "", err
+	}
+	token, err := ClientToken(m.TypeName, desired)
+	if err != nil {
+		return "", err
+	}
+	ds := string(desiredState)
+	pe, err := awsProvider.CreateResource(ctx, cc, m.TypeName, &ds, token, false)
+	if err != nil {
+		return "", err
+	}
+	if pe.Identifier == nil {
+		return "", fmt.Errorf("%s was created without returning an identifier", m.Name)
+	}
+	return *pe.Identifier, nil
+}
@@ -0,0 +1,289 @@
+package crudl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"os"
+	"sort"
+)
+
+// maxCascadeDepth limits how many levels of dependents are followed from the resources being deleted
+const maxCascadeDepth = 5
+
+// dependentFinder finds the live resources that refer to a resource, listing each dependent type at most once.
+type dependentFinder struct {
+	ctx   context.Context
+	cc    *cloudcontrol.Client
+	index data.Index
+	// dependents are the references to each type, see data.Index.Dependents
+	dependents map[string][]data.Reference
+	lists      map[string][]map[string]interface{}
+	ids        map[string][]string
+	visited    map[string]bool
+	// dependsOn records every reference found between the resources that have been visited, by the key of the
+	// resource that refers to the keys of the resources it refers to
+	dependsOn map[string]map[string]bool
+}
+
+func newDependentFinder(ctx context.Context, cc *cloudcontrol.Client, index data.Index) dependentFinder {
+	return dependentFinder{
+		ctx:        ctx,
+		cc:         cc,
+		index:      index,
+		dependents: index.Dependents(),
+		lists:      map[string][]map[string]interface{}{},
+		ids:        map[string][]string{},
+		visited:    map[string]bool{},
+		dependsOn:  map[string]map[string]bool{},
+	}
+}
+
+// CascadeDeleteResources deletes resources together with the live resources that depend on them, eg. a VPC's subnets
+// and security groups. Dependents are found from the reference properties in the cached schemas and are deleted
+// before the resources they depend on. refs are resolved with ResolveIdentifier, ids are used as they are.
+func CascadeDeleteResources(ctx context.Context, typeName string, refs []string, ids []string, noPrompts bool, async bool) {
+	index, err := data.GetIndex()
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	finder := newDependentFinder(ctx, cc, *index)
+	var roots []*resourceNode
+	for _, id := range resolveIdentifiers(ctx, typeName, refs, ids, noPrompts) {
+		root := &resourceNode{TypeName: typeName, Identifier: id}
+		finder.visited[root.key()] = true
+		roots = append(roots, root)
+	}
+	if len(roots) == 0 {
+		return
+	}
+	fmt.Println("Finding dependent resources...")
+	count := 0
+	for _, root := range roots {
+		finder.addDependents(root, 1)
+		root.walk(0, func(node *resourceNode, depth int) { count++ })
+	}
+	var checks []permissionCheck
+	var nodes []*resourceNode
+	for _, root := range roots {
+		root.walk(0, func(node *resourceNode, depth int) {
+			checks = append(checks, permissionCheck{"delete", node.TypeName})
+			nodes = append(nodes, node)
+		})
+	}
+	if err := preflight(ctx, checks...); err != nil {
+		ReportError(err)
+		return
+	}
+	protected, err := protectedResources(ctx, cc, nodes)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	if noPrompts && len(protected) > 0 {
+		ReportError(protectedError(protected))
+		return
+	}
+	fmt.Println("Deletion plan, dependents are deleted before the resources they depend on:")
+	for _, root := range roots {
+		root.Print(os.Stdout)
+	}
+	if !noPrompts {
+		printProtected(protected)
+		if !confirmed(fmt.Sprintf("Are you sure you want to delete these %d resources", count)) || !confirmProtected(protected) {
+			fmt.Println("Exiting without deleting anything.")
+			return
+		}
+	}
+	deleteBottomUp(ctx, *cc, nodes, finder.dependsOn, async)
+}
+
+// addDependents adds the live resources that refer to node as its children, and their dependents below them. A
+// resource is only added to the tree once, but each of its references to node is recorded in dependsOn.
+func (f dependentFinder) addDependents(node *resourceNode, depth int) {
+	if depth > maxCascadeDepth {
+		return
+	}
+	for _, ref := range f.dependents[node.TypeName] {
+		resources, ids := f.list(ref.TypeName)
+		for i, props := range resources {
+			if !data.Contains(data.ReferenceValues(props, ref.Path), node.Identifier) {
+				continue
+			}
+			child := &resourceNode{TypeName: ref.TypeName, Identifier: ids[i], Relation: "refers to it by " + ref.Path}
+			if f.dependsOn[child.key()] == nil {
+				f.dependsOn[child.key()] = map[string]bool{}
+			}
+			f.dependsOn[child.key()][node.key()] = true
+			if f.visited[child.key()] {
+				continue
+			}
+			f.visited[child.key()] = true
+			node.Children = append(node.Children, child)
+			f.addDependents(child, depth+1)
+		}
+	}
+}
+
+// list returns the properties and identifiers of typeName's live resources. Listed properties are often a summary, so
+// resources are read when the listed properties are missing the type's references.
+func (f dependentFinder) list(typeName string) ([]map[string]interface{}, []string) {
+	if resources, ok := f.lists[typeName]; ok {
+		return resources, f.ids[typeName]
+	}
+	f.lists[typeName] = nil
+	listed, err := awsProvider.ListResource(f.ctx, typeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: could not list %s to find dependents: %s\n", typeName, err.Error())
+		return nil, nil
+	}
+	cacheList(f.ctx, typeName, *listed)
+	entry, _ := f.index.Entry(typeName)
+	var summaries []string
+	listedProps := map[string]map[string]interface{}{}
+	for _, r := range *listed {
+		props := map[string]interface{}{}
+		if r.Properties != nil {
+			_ = json.Unmarshal([]byte(*r.Properties), &props)
+		}
+		listedProps[*r.Identifier] = props
+		if len(entry.References) > 0 && !hasReferences(props, entry.References) {
+			summaries = append(summaries, *r.Identifier)
+		}
+	}
+	// the resources whose summaries are missing their references are read in parallel
+	read, errs := awsProvider.AsyncCcGetResources(f.ctx, *f.cc, typeName, summaries)
+	for i, id := range summaries {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: could not read %s %s to find dependents: %s\n", typeName, id, errs[i].Error())
+			delete(listedProps, id)
+			continue
+		}
+		props := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(*read[i]), &props)
+		listedProps[id] = props
+	}
+	for _, r := range *listed {
+		props, ok := listedProps[*r.Identifier]
+		if !ok {
+			continue
+		}
+		f.lists[typeName] = append(f.lists[typeName], props)
+		f.ids[typeName] = append(f.ids[typeName], *r.Identifier)
+	}
+	return f.lists[typeName], f.ids[typeName]
+}
+
+// lookup finds a live resource of typeName by its identifier, or by any top level property with the value, eg. a role
+// referred to by its Arn.
+func (f dependentFinder) lookup(typeName string, value string) (string, map[string]interface{}, bool) {
+	resources, ids := f.list(typeName)
+	for i, id := range ids {
+		if id == value {
+			return id, resources[i], true
+		}
+	}
+	for i, props := range resources {
+		for _, v := range props {
+			if s, ok := v.(string); ok && s == value {
+				return ids[i], props, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// hasReferences reports whether props contains any of the reference properties in paths.
+func hasReferences(props map[string]interface{}, paths map[string]string) bool {
+	for path := range paths {
+		if _, ok := data.GetPath(props, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteBottomUp deletes resources after every resource that refers to them, in reverse topological order of the
+// references in dependsOn. Resources are deleted in waves, each wave holding the resources whose dependents have all
+// been deleted, and waiting for it to complete before the next. Only the last wave honours async. Deletion stops at
+// the first wave with a failure.
+func deleteBottomUp(ctx context.Context, cc cloudcontrol.Client, nodes []*resourceNode, dependsOn map[string]map[string]bool, async bool) {
+	waves := deletionWaves(nodes, dependsOn)
+	for i, wave := range waves {
+		last := i == len(waves)-1
+		byType := map[string][]string{}
+		var typeNames []string
+		for _, node := range wave {
+			if _, ok := byType[node.TypeName]; !ok {
+				typeNames = append(typeNames, node.TypeName)
+			}
+			byType[node.TypeName] = append(byType[node.TypeName], node.Identifier)
+		}
+		sort.Strings(typeNames)
+		var failed []string
+		for _, typeName := range typeNames {
+			ids := byType[typeName]
+			errs := awsProvider.AsyncCcDeleteResource(ctx, cc, typeName, ids, async && last)
+			failed = append(failed, reportBatch(typeName, ids, errs)...)
+		}
+		if len(failed) > 0 && !last {
+			fmt.Fprintf(os.Stderr, "Stopping because dependents %s could not be deleted\n", failed)
+			return
+		}
+	}
+}
+
+// deletionWaves orders nodes so that every resource comes in a later wave than the resources that refer to it. A
+// resource's wave is one after the latest wave of the resources that refer to it. References that form a cycle can't
+// be ordered, the reference that closes the cycle is ignored.
+func deletionWaves(nodes []*resourceNode, dependsOn map[string]map[string]bool) [][]*resourceNode {
+	referredBy := map[string][]string{}
+	for from, targets := range dependsOn {
+		for to := range targets {
+			referredBy[to] = append(referredBy[to], from)
+		}
+	}
+	known := map[string]bool{}
+	for _, node := range nodes {
+		known[node.key()] = true
+	}
+	waveOf := map[string]int{}
+	visiting := map[string]bool{}
+	var wave func(key string) int
+	wave = func(key string) int {
+		if w, ok := waveOf[key]; ok {
+			return w
+		}
+		visiting[key] = true
+		w := 0
+		for _, from := range referredBy[key] {
+			if !known[from] || visiting[from] {
+				continue
+			}
+			if fw := wave(from) + 1; fw > w {
+				w = fw
+			}
+		}
+		visiting[key] = false
+		waveOf[key] = w
+		return w
+	}
+	var waves [][]*resourceNode
+	for _, node := range nodes {
+		w := wave(node.key())
+		for len(waves) <= w {
+			waves = append(waves, nil)
+		}
+		waves[w] = append(waves[w], node)
+	}
+	return waves
+}
@@ -1,14 +1,121 @@
 package crudl
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"github.com/jaymccon/cloudctl/data"
 	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"regexp"
 )
 
-func CreateResource(typeName string, properties string, noPrompts bool, async bool) {
-	cc, err := awsProvider.NewCcClient()
+// clientTokenPattern is the format cloud control accepts for client tokens.
+var clientTokenPattern = regexp.MustCompile(`^[-A-Za-z0-9+/=]{1,128}$`)
+
+// CreateResource creates a resource from its json properties. Creates are idempotent, if clientToken is empty a token
+// is derived from the type and properties, so running the same create again doesn't make a duplicate. A resource
+// that already exists is reported as a warning rather than treated as a failure.
+func CreateResource(ctx context.Context, typeName string, properties string, clientToken string, noPrompts bool, async bool) {
+	schema, err := data.GetSchema(typeName)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	desired := map[string]interface{}{}
+	err = json.Unmarshal([]byte(properties), &desired)
+	if err != nil {
+		ReportError(Invalid(err))
+		return
+	}
+	if clientToken == "" {
+		clientToken, err = ClientToken(typeName, desired)
+		if err != nil {
+			ReportError(err)
+			return
+		}
+	} else if !clientTokenPattern.MatchString(clientToken) {
+		ReportError(Invalidf("client tokens are up to 128 letters, digits and -+/= characters"))
+		return
+	}
+	if err := preflight(ctx, permissionCheck{"create", typeName}); err != nil {
+		ReportError(err)
+		return
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	if id, ok := existingIdentifier(ctx, cc, *schema, desired); ok {
+		ReportError(alreadyExists(typeName, id, fmt.Sprintf("%s with identifier %q already exists, nothing was created", typeName, id)))
+		return
+	}
+	if !noPrompts {
+		props, err := indentedYaml(desired, "  ")
+		if err != nil {
+			ReportError(err)
+			return
+		}
+		fmt.Printf("%s will be created with the properties:\n%s", typeName, props)
+		if !confirmed(fmt.Sprintf("Are you sure you want to create this %s resource", typeName)) {
+			fmt.Println("Exiting without creating anything.")
+			return
+		}
+	}
+	err = awsProvider.AsyncCcCreateResource(ctx, *cc, typeName, properties, clientToken, async)
+	if errors.Is(err, awsProvider.ErrAlreadyExists) {
+		ReportError(alreadyExists(typeName, "", fmt.Sprintf("%s already exists, nothing was created: %s", typeName, err.Error())))
+		return
+	}
+	if err != nil {
+		ReportError(err)
+	}
+}
+
+// ClientToken derives a client token from a type and its desired state. Cloud control carries out requests with the
+// same token once, so a create that is retried or run again returns the original request. Tokens are remembered for a
+// while, so creating the same properties again soon after deleting the resource needs a different token.
+func ClientToken(typeName string, desired map[string]interface{}) (string, error) {
+	// maps are marshalled with sorted keys, so equal states hash the same however they were written
+	canonical, err := json.Marshal(desired)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(typeName+"\n"), canonical...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// alreadyExists is the warning for a create whose resource already exists, it doesn't fail the command.
+func alreadyExists(typeName string, id string, message string) *Error {
+	return &Error{
+		Message:          message,
+		ExitCode:         ExitOK,
+		HandlerErrorCode: string(types.HandlerErrorCodeAlreadyExists),
+		TypeName:         typeName,
+		Identifier:       id,
+	}
+}
+
+// existingIdentifier returns the identifier of the resource that desired would create, when the properties of its
+// primary identifier are set in desired and a resource with that identifier already exists.
+func existingIdentifier(ctx context.Context, cc *cloudcontrol.Client, schema data.CfnSchema, desired map[string]interface{}) (string, bool) {
+	parts := map[string]string{}
+	for _, name := range schema.PrimaryIdentifierNames() {
+		v, ok := data.GetPath(desired, name)
+		if !ok {
+			return "", false
+		}
+		parts[name] = data.ToString(v)
+	}
+	id, err := schema.BuildIdentifier(parts)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
+		return "", false
 	}
-	awsProvider.AsyncCcCreateResource(*cc, typeName, properties, async)
+	_, err = awsProvider.GetResource(ctx, cc, schema.TypeName, id)
+	return id, err == nil
 }
@@ -1,23 +1,51 @@
 package crudl
 
 import (
+	"context"
 	"fmt"
 	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
 )
 
-func DeleteResources(typeName string, ids []string, noPrompts bool, async bool) {
+// DeleteResources deletes resources concurrently. refs are resolved with ResolveIdentifier, ids are deleted as they
+// are.
+func DeleteResources(ctx context.Context, typeName string, refs []string, ids []string, noPrompts bool, async bool) {
+	ids = resolveIdentifiers(ctx, typeName, refs, ids, noPrompts)
+	if len(ids) == 0 {
+		return
+	}
+	if err := preflight(ctx, permissionCheck{"delete", typeName}); err != nil {
+		ReportError(err)
+		return
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	var resources []*resourceNode
+	for _, id := range ids {
+		resources = append(resources, &resourceNode{TypeName: typeName, Identifier: id})
+	}
+	protected, err := protectedResources(ctx, cc, resources)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	if noPrompts && len(protected) > 0 {
+		ReportError(protectedError(protected))
+		return
+	}
 	if !noPrompts {
-		if !confirm(fmt.Sprintf("Are you sure you want to delete %s resources with identifiers %s", typeName, ids)) {
+		fmt.Printf("%d %s resources will be deleted:\n", len(ids), typeName)
+		for _, id := range ids {
+			fmt.Printf("  %s\n", id)
+		}
+		printProtected(protected)
+		if !confirmed(fmt.Sprintf("Are you sure you want to delete these %d resources", len(ids))) || !confirmProtected(protected) {
 			fmt.Println("Exiting without deleting anything.")
 			return
 		}
-		if noPrompts {
-			fmt.Println("--no-prompt flag set, skipping confirmation.")
-		}
-	}
-	cc, err := awsProvider.NewCcClient()
-	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
 	}
-	awsProvider.AsyncCcDeleteResource(*cc, typeName, ids, async)
+	errs := awsProvider.AsyncCcDeleteResource(ctx, *cc, typeName, ids, async)
+	reportBatch(typeName, ids, errs)
 }
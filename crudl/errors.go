@@ -0,0 +1,205 @@
+package crudl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Exit codes, the first error reported during a command decides the code cloudctl exits with.
+const (
+	ExitOK = 0
+	// ExitError is an error that doesn't fit another code, eg. the schema cache can't be opened
+	ExitError = 1
+	// ExitValidation is input that cloudctl rejected before calling AWS
+	ExitValidation = 2
+	ExitNotFound   = 3
+	// ExitApiFailure is a call to AWS that failed, eg. because of missing credentials or throttling
+	ExitApiFailure = 4
+	// ExitOperationFailed is an operation that cloud control accepted and then reported as FAILED
+	ExitOperationFailed = 5
+	// ExitPartialFailure is a batch where some resources failed and others succeeded
+	ExitPartialFailure = 6
+)
+
+// JsonErrors prints errors as one JSON object per line, it is set when the output is json.
+var JsonErrors bool
+
+var reported = struct {
+	sync.Mutex
+	exitCode int
+}{}
+
+// Error is an error with the exit code it results in and, when it came from cloud control, the handler error code and
+// the resource it was about.
+type Error struct {
+	Message          string `json:"Error"`
+	ExitCode         int    `json:"ExitCode"`
+	HandlerErrorCode string `json:"HandlerErrorCode,omitempty"`
+	TypeName         string `json:"TypeName,omitempty"`
+	Identifier       string `json:"Identifier,omitempty"`
+	RequestToken     string `json:"RequestToken,omitempty"`
+	// ServiceRole is the role the resource handler ran as
+	ServiceRole string `json:"ServiceRole,omitempty"`
+	// Hint suggests how to fix the problem
+	Hint string `json:"Hint,omitempty"`
+	// RequiredPermissions are the IAM actions the operation needs, they are listed when access was denied
+	RequiredPermissions []string `json:"RequiredPermissions,omitempty"`
+	cause               error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+func Invalid(err error) *Error {
+	return &Error{Message: err.Error(), ExitCode: ExitValidation, cause: err}
+}
+
+func Invalidf(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), ExitCode: ExitValidation}
+}
+
+func notFoundf(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), ExitCode: ExitNotFound}
+}
+
+// toError classifies err by where it came from, errors that are already classified are returned as is.
+func toError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	e = &Error{Message: err.Error(), ExitCode: ExitError, cause: err}
+	if awsProvider.IsApiError(err) {
+		e.ExitCode = ExitApiFailure
+	}
+	verb := ""
+	var apiErr *awsProvider.ApiError
+	if errors.As(err, &apiErr) {
+		verb, e.TypeName, e.Identifier = apiErr.Verb, apiErr.TypeName, apiErr.Identifier
+	}
+	var opErr *awsProvider.OperationError
+	if errors.As(err, &opErr) {
+		e.ExitCode = ExitOperationFailed
+		verb, e.TypeName, e.Identifier, e.RequestToken = opErr.Verb, opErr.TypeName, opErr.Identifier, opErr.RequestToken
+	}
+	if e.TypeName != "" {
+		e.ServiceRole = awsProvider.ServiceRoleArn
+	}
+	if code, ok := awsProvider.HandlerErrorCode(err); ok {
+		e.HandlerErrorCode = string(code)
+		if code == types.HandlerErrorCodeNotFound {
+			e.ExitCode = ExitNotFound
+		}
+	}
+	if h, ok := awsProvider.AsHandlerError(err); ok {
+		e.Hint = h.Hint
+		if h == awsProvider.ErrAccessDenied {
+			e.RequiredPermissions = requiredPermissions(e.TypeName, verb)
+		}
+		if len(e.RequiredPermissions) > 0 {
+			needs := verb
+			if opErr != nil && e.ServiceRole != "" {
+				// the handler's permissions come from the service role rather than the caller
+				needs = "the service role"
+			}
+			e.Hint = fmt.Sprintf("%s, %s needs: %s", e.Hint, needs, strings.Join(e.RequiredPermissions, ", "))
+		}
+	}
+	return e
+}
+
+// requiredPermissions returns the IAM actions listed in typeName's schema for the handler of verb.
+func requiredPermissions(typeName string, verb string) []string {
+	if typeName == "" || verb == "" {
+		return nil
+	}
+	schema, err := data.GetSchema(typeName)
+	if err != nil {
+		return nil
+	}
+	return schema.Handlers.Permissions(verb)
+}
+
+// resourceError is err about a resource, the resource is added to err if it doesn't already name one.
+func resourceError(err error, typeName string, id string) *Error {
+	e := toError(err)
+	if e.TypeName == "" {
+		copied := *e
+		copied.TypeName, copied.Identifier = typeName, id
+		copied.Message = fmt.Sprintf("%s %s: %s", typeName, id, e.Message)
+		e = &copied
+	}
+	return e
+}
+
+// ReportError prints err to stderr and records its exit code. Operations that stopped because the command was
+// interrupted or timed out are not printed, their requests are listed once every operation has stopped. Errors with
+// ExitOK are warnings.
+func ReportError(err error) {
+	e := toError(err)
+	reported.Lock()
+	defer reported.Unlock()
+	if reported.exitCode == ExitOK {
+		reported.exitCode = e.ExitCode
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	if JsonErrors {
+		b, _ := json.Marshal(e)
+		fmt.Fprintln(os.Stderr, string(b))
+		return
+	}
+	level := "ERROR"
+	if e.ExitCode == ExitOK {
+		// warnings are reported like errors but don't fail the command
+		level = "WARNING"
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", level, e.Message)
+	if e.Hint != "" {
+		fmt.Fprintf(os.Stderr, "  hint: %s\n", e.Hint)
+	}
+}
+
+// reportBatch reports the errors of a batch of operations on typeName's resources, errs is in the same order as ids
+// with nil for the resources that succeeded. A batch that partly succeeded exits with ExitPartialFailure. It returns
+// the identifiers that failed.
+func reportBatch(typeName string, ids []string, errs []error) []string {
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			ReportError(resourceError(err, typeName, ids[i]))
+			failed = append(failed, ids[i])
+		}
+	}
+	if len(failed) > 0 && len(failed) < len(ids) {
+		setExitCode(ExitPartialFailure)
+	}
+	return failed
+}
+
+func setExitCode(code int) {
+	reported.Lock()
+	defer reported.Unlock()
+	reported.exitCode = code
+}
+
+// ExitCode is the code cloudctl should exit with for the errors reported so far.
+func ExitCode() int {
+	reported.Lock()
+	defer reported.Unlock()
+	return reported.exitCode
+}
@@ -0,0 +1,298 @@
+package crudl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExportFormats are the template formats supported by Export.
+var ExportFormats = []string{"cfn", "cfn-json", "terraform"}
+
+var nonAlphanumeric = regexp.MustCompile("[^A-Za-z0-9]+")
+
+// exportedResource is a live resource being written to a template.
+type exportedResource struct {
+	TypeName   string
+	Identifier string
+	LogicalId  string
+	schema     *data.CfnSchema
+	// current is the resource's state as read, including read only properties that other resources may refer to
+	current map[string]interface{}
+	// desired is the state written to the template
+	desired map[string]interface{}
+}
+
+// exportRef is a reference from one exported resource to another, a Ref if Attribute is empty or a GetAtt otherwise.
+type exportRef struct {
+	LogicalId string
+	Attribute string
+}
+
+type cfnTemplate struct {
+	AWSTemplateFormatVersion string                 `yaml:"AWSTemplateFormatVersion" json:"AWSTemplateFormatVersion"`
+	Description              string                 `yaml:"Description" json:"Description"`
+	Resources                map[string]cfnResource `yaml:"Resources" json:"Resources"`
+}
+
+type cfnResource struct {
+	Type           string                 `yaml:"Type" json:"Type"`
+	DeletionPolicy string                 `yaml:"DeletionPolicy" json:"DeletionPolicy"`
+	Properties     map[string]interface{} `yaml:"Properties,omitempty" json:"Properties,omitempty"`
+}
+
+// Export writes live resources as an infrastructure as code template. Read only properties are removed, and
+// properties that refer to other exported resources become references. With related, the resources that the
+// exported resources refer to are exported too.
+func Export(ctx context.Context, typeName string, refs []string, format string, related bool, noPrompts bool) {
+	if !data.Contains(ExportFormats, format) {
+		ReportError(Invalidf("unsupported format %q, supported formats are %s", format, strings.Join(ExportFormats, ", ")))
+		return
+	}
+	index, err := data.GetIndex()
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	finder := newDependentFinder(ctx, cc, *index)
+	var resources []*exportedResource
+	exported := map[string]bool{}
+	add := func(typeName string, id string) error {
+		key := typeName + "|" + id
+		if exported[key] {
+			return nil
+		}
+		exported[key] = true
+		r, err := readExportedResource(ctx, finder, typeName, id)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, r)
+		return nil
+	}
+	for _, ref := range refs {
+		id, err := ResolveIdentifier(ctx, typeName, ref, noPrompts)
+		if err != nil {
+			ReportError(err)
+			return
+		}
+		if err := add(typeName, id); err != nil {
+			ReportError(resourceError(err, typeName, id))
+			return
+		}
+	}
+	// resources is appended to while related resources are found, so this also follows their references
+	for i := 0; related && i < len(resources); i++ {
+		r := resources[i]
+		for _, ref := range index.References(r.TypeName) {
+			for _, v := range data.ReferenceValues(r.current, ref.Path) {
+				id, _, ok := finder.lookup(ref.Target, v)
+				if !ok {
+					continue
+				}
+				if err := add(ref.Target, id); err != nil {
+					fmt.Fprintf(os.Stderr, "WARNING: not exporting %s %s: %s\n", ref.Target, id, err.Error())
+				}
+			}
+		}
+	}
+	setLogicalIds(resources)
+	for _, r := range resources {
+		replaceReferences(*index, r, resources)
+	}
+	switch format {
+	case "terraform":
+		err = writeTerraform(os.Stdout, resources)
+	default:
+		err = writeCfn(os.Stdout, resources, format == "cfn-json")
+	}
+	if err != nil {
+		ReportError(err)
+	}
+}
+
+func readExportedResource(ctx context.Context, finder dependentFinder, typeName string, id string) (*exportedResource, error) {
+	schema, err := data.GetSchema(typeName)
+	if err != nil {
+		return nil, err
+	}
+	props, err := awsProvider.GetResource(ctx, finder.cc, typeName, id)
+	if err != nil {
+		return nil, err
+	}
+	r := &exportedResource{TypeName: typeName, Identifier: id, schema: schema}
+	if err := json.Unmarshal([]byte(*props), &r.current); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(*props), &r.desired); err != nil {
+		return nil, err
+	}
+	removeReadOnly(*schema, r.desired)
+	return r, nil
+}
+
+func deletePath(doc map[string]interface{}, parts []string) {
+	if len(parts) == 1 {
+		delete(doc, parts[0])
+		return
+	}
+	if child, ok := doc[parts[0]].(map[string]interface{}); ok {
+		deletePath(child, parts[1:])
+	}
+}
+
+// setLogicalIds names resources after their type and identifier, eg. BucketMyBucket for the bucket my-bucket.
+func setLogicalIds(resources []*exportedResource) {
+	used := map[string]bool{}
+	for _, r := range resources {
+		parts := strings.Split(r.TypeName, "::")
+		base := parts[len(parts)-1]
+		for _, word := range nonAlphanumeric.Split(r.Identifier, -1) {
+			if word != "" {
+				base += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+		id := base
+		for i := 2; used[id]; i++ {
+			id = base + strconv.Itoa(i)
+		}
+		used[id] = true
+		r.LogicalId = id
+	}
+}
+
+// replaceReferences replaces the values of r's reference properties that identify another exported resource with a
+// reference to it. Values matching the other resource's primary identifier become a Ref, and values matching one of its
+// read only properties, eg. an Arn, a GetAtt. Only read only properties are attributes that GetAtt accepts.
+func replaceReferences(index data.Index, r *exportedResource, resources []*exportedResource) {
+	for _, ref := range index.References(r.TypeName) {
+		replace := func(v interface{}) interface{} {
+			s, ok := v.(string)
+			if !ok {
+				return v
+			}
+			for _, target := range resources {
+				if target.TypeName != ref.Target {
+					continue
+				}
+				if target.Identifier == s {
+					return exportRef{LogicalId: target.LogicalId}
+				}
+				for _, attribute := range getAttAttributes(*target.schema) {
+					if v, ok := data.GetPath(target.current, attribute); ok && v == s {
+						return exportRef{LogicalId: target.LogicalId, Attribute: attribute}
+					}
+				}
+			}
+			return v
+		}
+		parts := strings.Split(ref.Path, ".")
+		parent := r.desired
+		if len(parts) > 1 {
+			v, ok := data.GetPath(r.desired, strings.Join(parts[:len(parts)-1], "."))
+			if parent, ok = v.(map[string]interface{}); !ok {
+				continue
+			}
+		}
+		name := parts[len(parts)-1]
+		switch v := parent[name].(type) {
+		case []interface{}:
+			for i := range v {
+				v[i] = replace(v[i])
+			}
+		case string:
+			parent[name] = replace(v)
+		}
+	}
+}
+
+// getAttAttributes returns the attributes of a type that GetAtt can return, its read only properties in the dotted
+// form GetAtt uses for nested attributes, eg. Endpoint.Address.
+func getAttAttributes(schema data.CfnSchema) []string {
+	var attributes []string
+	for _, p := range schema.ReadOnlyProperties {
+		path := strings.TrimPrefix(p, "/properties/")
+		// attributes of array items can't be addressed
+		if strings.Contains(path, "*") {
+			continue
+		}
+		attributes = append(attributes, strings.Replace(path, "/", ".", -1))
+	}
+	sort.Strings(attributes)
+	return attributes
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeCfn(w io.Writer, resources []*exportedResource, asJson bool) error {
+	template := cfnTemplate{
+		AWSTemplateFormatVersion: "2010-09-09",
+		Description:              "Exported by cloudctl",
+		Resources:                map[string]cfnResource{},
+	}
+	for _, r := range resources {
+		props, _ := cfnValue(r.desired).(map[string]interface{})
+		// resources must have a DeletionPolicy to be imported into a stack, retaining them also means deleting the
+		// stack can't delete resources that were created outside of it
+		template.Resources[r.LogicalId] = cfnResource{Type: r.TypeName, DeletionPolicy: "Retain", Properties: props}
+	}
+	if asJson {
+		out, err := json.MarshalIndent(template, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(out, '\n'))
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(template); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// cfnValue replaces references in v with CloudFormation intrinsic functions.
+func cfnValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case exportRef:
+		if t.Attribute == "" {
+			return map[string]interface{}{"Ref": t.LogicalId}
+		}
+		return map[string]interface{}{"Fn::GetAtt": []interface{}{t.LogicalId, t.Attribute}}
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, c := range t {
+			m[k] = cfnValue(c)
+		}
+		return m
+	case []interface{}:
+		var l []interface{}
+		for _, c := range t {
+			l = append(l, cfnValue(c))
+		}
+		return l
+	}
+	return v
+}
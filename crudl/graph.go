@@ -0,0 +1,154 @@
+package crudl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"io"
+	"os"
+	"strings"
+)
+
+// GraphFormats are the output formats supported by Graph.
+var GraphFormats = []string{"tree", "dot", "mermaid"}
+
+// graphEdge is a reference from one resource to another through the property at Path.
+type graphEdge struct {
+	From string
+	To   string
+	Path string
+}
+
+// resourceGraph is the live resources related to a resource, keyed by resourceNode.key.
+type resourceGraph struct {
+	root  *resourceNode
+	nodes map[string]*resourceNode
+	order []string
+	edges []graphEdge
+}
+
+// Graph prints the live resources related to a resource, both the resources it refers to and the resources that refer
+// to it, following relationships up to depth steps away.
+func Graph(ctx context.Context, typeName string, ref string, format string, depth int, noPrompts bool) {
+	if !data.Contains(GraphFormats, format) {
+		ReportError(Invalidf("unsupported format %q, supported formats are %s", format, strings.Join(GraphFormats, ", ")))
+		return
+	}
+	id, err := ResolveIdentifier(ctx, typeName, ref, noPrompts)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	index, err := data.GetIndex()
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	props, err := awsProvider.GetResource(ctx, cc, typeName, id)
+	if err != nil {
+		ReportError(resourceError(err, typeName, id))
+		return
+	}
+	rootProps := map[string]interface{}{}
+	err = json.Unmarshal([]byte(*props), &rootProps)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	finder := newDependentFinder(ctx, cc, *index)
+	g := buildGraph(ctx, finder, &resourceNode{TypeName: typeName, Identifier: id}, rootProps, depth)
+	switch format {
+	case "dot":
+		g.printDot(os.Stdout)
+	case "mermaid":
+		g.printMermaid(os.Stdout)
+	default:
+		g.root.Print(os.Stdout)
+	}
+}
+
+// buildGraph walks the relationships of root breadth first. Each resource is expanded once, the tree formed by the
+// nodes' Children is the order resources were discovered in.
+func buildGraph(ctx context.Context, finder dependentFinder, root *resourceNode, rootProps map[string]interface{}, depth int) resourceGraph {
+	g := resourceGraph{root: root, nodes: map[string]*resourceNode{root.key(): root}, order: []string{root.key()}}
+	properties := map[string]map[string]interface{}{root.key(): rootProps}
+	queue := []*resourceNode{root}
+	distance := map[string]int{root.key(): 0}
+	edges := map[graphEdge]bool{}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if distance[node.key()] >= depth {
+			continue
+		}
+		add := func(n *resourceNode, props map[string]interface{}, edge graphEdge) {
+			if !edges[edge] {
+				edges[edge] = true
+				g.edges = append(g.edges, edge)
+			}
+			if _, ok := g.nodes[n.key()]; ok {
+				return
+			}
+			g.nodes[n.key()] = n
+			g.order = append(g.order, n.key())
+			node.Children = append(node.Children, n)
+			if props != nil {
+				properties[n.key()] = props
+				distance[n.key()] = distance[node.key()] + 1
+				queue = append(queue, n)
+			}
+		}
+		for _, ref := range finder.index.References(node.TypeName) {
+			for _, v := range data.ReferenceValues(properties[node.key()], ref.Path) {
+				id, props, ok := finder.lookup(ref.Target, v)
+				if !ok {
+					id = v
+				}
+				target := &resourceNode{TypeName: ref.Target, Identifier: id, Relation: "referenced by " + ref.Path}
+				add(target, props, graphEdge{From: node.key(), To: target.key(), Path: ref.Path})
+			}
+		}
+		for _, ref := range finder.dependents[node.TypeName] {
+			resources, ids := finder.list(ref.TypeName)
+			for i, props := range resources {
+				if !data.Contains(data.ReferenceValues(props, ref.Path), node.Identifier) {
+					continue
+				}
+				dependent := &resourceNode{TypeName: ref.TypeName, Identifier: ids[i], Relation: "refers to it by " + ref.Path}
+				add(dependent, props, graphEdge{From: dependent.key(), To: node.key(), Path: ref.Path})
+			}
+		}
+	}
+	return g
+}
+
+func (g resourceGraph) printDot(w io.Writer) {
+	fmt.Fprintln(w, "digraph cloudctl {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	for _, key := range g.order {
+		fmt.Fprintf(w, "  %q;\n", g.nodes[key].label())
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(w, "  %q -> %q [label=%q];\n", g.nodes[e.From].label(), g.nodes[e.To].label(), e.Path)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func (g resourceGraph) printMermaid(w io.Writer) {
+	ids := map[string]string{}
+	fmt.Fprintln(w, "graph LR")
+	for i, key := range g.order {
+		ids[key] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(w, "  %s[\"%s\"]\n", ids[key], strings.Replace(g.nodes[key].label(), "\"", "#quot;", -1))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(w, "  %s -->|%s| %s\n", ids[e.From], e.Path, ids[e.To])
+	}
+}
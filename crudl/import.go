@@ -0,0 +1,258 @@
+package crudl
+
+import (
+	"context"
+	"fmt"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ImportTemplate converts the resources in a CloudFormation template into manifests for apply. Parameters, pseudo
+// parameters, mappings and conditions are resolved from the template and the Name=value parameters supplied. Intrinsic
+// functions that refer to other resources in the template are kept for apply to resolve, and any that can't be
+// resolved are listed in the manifest's unresolved properties. Manifests are written to stdout, or one file per
+// resource in outputDir.
+func ImportTemplate(ctx context.Context, path string, parameters []string, outputDir string) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		ReportError(Invalid(err))
+		return
+	}
+	template, err := parseTemplate(b)
+	if err != nil {
+		ReportError(Invalidf("parsing %s: %s", path, err.Error()))
+		return
+	}
+	resolver, err := newTemplateResolver(ctx, template, parameters)
+	if err != nil {
+		ReportError(Invalid(err))
+		return
+	}
+	manifests, unknown := templateManifests(template, resolver)
+	if unknown {
+		ReportError(Invalidf("the template has properties that are not in the resource schemas, no manifests were written"))
+		return
+	}
+	if outputDir == "" {
+		err = data.WriteManifests(os.Stdout, manifests)
+		if err != nil {
+			ReportError(err)
+		}
+		return
+	}
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	for _, m := range manifests {
+		f, err := os.Create(filepath.Join(outputDir, m.Name+".yaml"))
+		if err != nil {
+			ReportError(err)
+			return
+		}
+		err = data.WriteManifests(f, []data.Manifest{m})
+		f.Close()
+		if err != nil {
+			ReportError(err)
+			return
+		}
+	}
+	fmt.Printf("Wrote %d manifests to %s\n", len(manifests), outputDir)
+}
+
+// parseTemplate parses a yaml or json template. The short forms of intrinsic functions, eg. !Ref MyVpc, are converted
+// to their long forms.
+func parseTemplate(b []byte) (map[string]interface{}, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, err
+	}
+	v, err := nodeValue(&doc)
+	if err != nil {
+		return nil, err
+	}
+	template, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("a template should be an object")
+	}
+	if _, ok := template["Resources"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("the template does not have any Resources")
+	}
+	return template, nil
+}
+
+func nodeValue(n *yaml.Node) (interface{}, error) {
+	var v interface{}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return nodeValue(n.Content[0])
+	case yaml.AliasNode:
+		return nodeValue(n.Alias)
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			value, err := nodeValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[n.Content[i].Value] = value
+		}
+		v = m
+	case yaml.SequenceNode:
+		l := []interface{}{}
+		for _, c := range n.Content {
+			value, err := nodeValue(c)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, value)
+		}
+		v = l
+	default:
+		if strings.HasPrefix(n.Tag, "!") && !strings.HasPrefix(n.Tag, "!!") {
+			v = n.Value
+		} else if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+	}
+	if !strings.HasPrefix(n.Tag, "!") || strings.HasPrefix(n.Tag, "!!") {
+		return v, nil
+	}
+	fn := strings.TrimPrefix(n.Tag, "!")
+	if fn == "GetAtt" {
+		if s, ok := v.(string); ok {
+			parts := []interface{}{}
+			for _, p := range getAttParts(s) {
+				parts = append(parts, p)
+			}
+			v = parts
+		}
+	}
+	if fn != "Ref" && fn != "Condition" {
+		fn = "Fn::" + fn
+	}
+	return map[string]interface{}{fn: v}, nil
+}
+
+// newTemplateResolver returns a resolver for the template's parameters, pseudo parameters, mappings and conditions.
+// Supplied parameters override defaults, and can supply pseudo parameters like AWS::AccountId.
+func newTemplateResolver(ctx context.Context, template map[string]interface{}, parameters []string) (*intrinsicResolver, error) {
+	r := &intrinsicResolver{
+		parameters: map[string]interface{}{},
+		resources:  map[string]bool{},
+	}
+	r.mappings, _ = template["Mappings"].(map[string]interface{})
+	r.conditions, _ = template["Conditions"].(map[string]interface{})
+	if region := awsProvider.Region(ctx); region != "" {
+		partition, suffix := "aws", "amazonaws.com"
+		if strings.HasPrefix(region, "cn-") {
+			partition, suffix = "aws-cn", "amazonaws.com.cn"
+		} else if strings.HasPrefix(region, "us-gov-") {
+			partition = "aws-us-gov"
+		}
+		r.parameters["AWS::Region"] = region
+		r.parameters["AWS::Partition"] = partition
+		r.parameters["AWS::URLSuffix"] = suffix
+	}
+	params, _ := template["Parameters"].(map[string]interface{})
+	for name, iface := range params {
+		param, _ := iface.(map[string]interface{})
+		if value, ok := param["Default"]; ok {
+			if t, _ := param["Type"].(string); strings.HasPrefix(t, "List<") || t == "CommaDelimitedList" {
+				value = splitList(value)
+			}
+			r.parameters[name] = value
+		}
+	}
+	for _, p := range parameters {
+		name, value, err := data.ParseAssignment(p)
+		if err != nil {
+			return nil, err
+		}
+		param, ok := params[name].(map[string]interface{})
+		if !ok && !strings.HasPrefix(name, "AWS::") {
+			return nil, fmt.Errorf("the template does not have a parameter %q", name)
+		}
+		if t, _ := param["Type"].(string); strings.HasPrefix(t, "List<") || t == "CommaDelimitedList" {
+			r.parameters[name] = splitList(value)
+		} else {
+			r.parameters[name] = value
+		}
+	}
+	resources, _ := template["Resources"].(map[string]interface{})
+	for name := range resources {
+		r.resources[name] = true
+	}
+	return r, nil
+}
+
+func splitList(v interface{}) []interface{} {
+	var values []interface{}
+	for _, s := range strings.Split(data.ToString(v), ",") {
+		values = append(values, strings.TrimSpace(s))
+	}
+	return values
+}
+
+// templateManifests converts the template's resources to manifests, reporting resources that can't be managed with
+// cloud control and properties that could not be resolved. unknown is true if any property is not in its schema.
+func templateManifests(template map[string]interface{}, r *intrinsicResolver) ([]data.Manifest, bool) {
+	resources, _ := template["Resources"].(map[string]interface{})
+	var names []string
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	unknown := false
+	var manifests []data.Manifest
+	for _, name := range names {
+		resource, _ := resources[name].(map[string]interface{})
+		typeName, _ := resource["Type"].(string)
+		schema, err := data.GetSchema(typeName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: skipping %s, %s is not a cached resource type\n", name, typeName)
+			continue
+		}
+		r.problems = nil
+		if condition, ok := resource["Condition"].(string); ok {
+			create, ok := r.condition("Condition", condition)
+			if ok && !create {
+				fmt.Fprintf(os.Stderr, "Skipping %s, condition %s is false\n", name, condition)
+				continue
+			}
+		}
+		m := data.Manifest{TypeName: typeName, Name: name}
+		props, _ := resource["Properties"].(map[string]interface{})
+		resolvedProps, _ := r.resolve("", props)
+		m.Properties, _ = resolvedProps.(map[string]interface{})
+		switch dependsOn := resource["DependsOn"].(type) {
+		case string:
+			m.DependsOn = []string{dependsOn}
+		case []interface{}:
+			for _, d := range dependsOn {
+				m.DependsOn = append(m.DependsOn, data.ToString(d))
+			}
+		}
+		m.Unresolved = r.problems
+		for _, p := range m.Unresolved {
+			fmt.Fprintf(os.Stderr, "WARNING: %s %s\n", name, p)
+		}
+		for _, p := range schema.UnknownProperties(m.Properties) {
+			ReportError(Invalidf("%s %s does not have a property %q", name, typeName, p))
+			unknown = true
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, unknown
+}
@@ -0,0 +1,431 @@
+package crudl
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/jaymccon/cloudctl/data"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// resolution is how far an expression could be evaluated, the resolution of an expression is the worst of its
+// arguments'.
+type resolution int
+
+const (
+	resolved resolution = iota
+	// deferred expressions refer to resources in the same template or manifest set, they are evaluated by apply once
+	// those resources exist
+	deferred
+	// unresolved expressions can't be evaluated by cloudctl, eg. Fn::ImportValue
+	unresolved
+)
+
+func worst(a resolution, b resolution) resolution {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// noValue is the result of Ref AWS::NoValue, properties with no value are removed.
+type noValue struct{}
+
+// supportedFunctions are the functions, other than Ref, Fn::GetAtt, Fn::Sub and Fn::If, that resolve can evaluate
+var supportedFunctions = map[string]bool{
+	"Fn::Join":         true,
+	"Fn::Select":       true,
+	"Fn::Split":        true,
+	"Fn::FindInMap":    true,
+	"Fn::Base64":       true,
+	"Fn::Length":       true,
+	"Fn::ToJsonString": true,
+}
+
+var subVariable = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// intrinsicResolver evaluates CloudFormation intrinsic functions.
+type intrinsicResolver struct {
+	// parameters are template parameter and pseudo parameter values
+	parameters map[string]interface{}
+	mappings   map[string]interface{}
+	conditions map[string]interface{}
+	// resources are the names of the resources in the template or manifest set
+	resources map[string]bool
+	// attribute returns a resource's identifier when attr is empty, or one of its properties, for resources that have
+	// been applied. A nil attribute defers every reference to a resource.
+	attribute func(name string, attr string) (interface{}, bool)
+	// problems describes the expressions that could not be resolved
+	problems []string
+	// evaluating detects conditions that refer to themselves
+	evaluating map[string]bool
+}
+
+// resolve evaluates the intrinsic functions in v. Deferred and unresolved expressions are returned with their
+// resolvable arguments evaluated.
+func (r *intrinsicResolver) resolve(path string, v interface{}) (interface{}, resolution) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if data.IsIntrinsic(t) {
+			for fn, arg := range t {
+				return r.resolveFunction(path, fn, arg)
+			}
+		}
+		m := map[string]interface{}{}
+		res := resolved
+		for _, k := range sortedKeys(t) {
+			value, childRes := r.resolve(joinPath(path, k), t[k])
+			res = worst(res, childRes)
+			if _, ok := value.(noValue); !ok {
+				m[k] = value
+			}
+		}
+		return m, res
+	case []interface{}:
+		l := []interface{}{}
+		res := resolved
+		for i, item := range t {
+			value, childRes := r.resolve(fmt.Sprintf("%s[%d]", path, i), item)
+			res = worst(res, childRes)
+			if _, ok := value.(noValue); !ok {
+				l = append(l, value)
+			}
+		}
+		return l, res
+	}
+	return v, resolved
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func (r *intrinsicResolver) unresolved(path string, fn string, reason string) resolution {
+	r.problems = append(r.problems, fmt.Sprintf("%s: %s %s", path, fn, reason))
+	return unresolved
+}
+
+func (r *intrinsicResolver) resolveFunction(path string, fn string, arg interface{}) (interface{}, resolution) {
+	original := map[string]interface{}{fn: arg}
+	if fn == "Ref" {
+		name, _ := arg.(string)
+		return r.ref(path, name, "", original)
+	}
+	if fn == "Fn::GetAtt" {
+		parts := getAttParts(arg)
+		if len(parts) != 2 {
+			return original, r.unresolved(path, fn, "should have a resource name and an attribute")
+		}
+		return r.ref(path, parts[0], parts[1], map[string]interface{}{fn: []interface{}{parts[0], parts[1]}})
+	}
+	if fn == "Fn::Sub" {
+		return r.sub(path, arg)
+	}
+	if fn == "Fn::If" {
+		args, ok := arg.([]interface{})
+		if !ok || len(args) != 3 {
+			return original, r.unresolved(path, fn, "should have a condition and two values")
+		}
+		name, _ := args[0].(string)
+		value, ok := r.condition(path, name)
+		if !ok {
+			return original, unresolved
+		}
+		if value {
+			return r.resolve(path, args[1])
+		}
+		return r.resolve(path, args[2])
+	}
+	if !supportedFunctions[fn] {
+		return original, r.unresolved(path, fn, "can't be resolved by cloudctl")
+	}
+	resolvedArg, res := r.resolve(path, arg)
+	if res != resolved {
+		return map[string]interface{}{fn: resolvedArg}, res
+	}
+	args, _ := resolvedArg.([]interface{})
+	switch fn {
+	case "Fn::Join":
+		if len(args) == 2 {
+			delimiter, ok := args[0].(string)
+			values, listOk := args[1].([]interface{})
+			if ok && listOk {
+				var parts []string
+				for _, value := range values {
+					parts = append(parts, data.ToString(value))
+				}
+				return strings.Join(parts, delimiter), resolved
+			}
+		}
+	case "Fn::Select":
+		if len(args) == 2 {
+			index, err := strconv.Atoi(data.ToString(args[0]))
+			values, ok := args[1].([]interface{})
+			if err == nil && ok && index >= 0 && index < len(values) {
+				return values[index], resolved
+			}
+		}
+	case "Fn::Split":
+		if len(args) == 2 {
+			delimiter, ok := args[0].(string)
+			s, strOk := args[1].(string)
+			if ok && strOk {
+				var values []interface{}
+				for _, part := range strings.Split(s, delimiter) {
+					values = append(values, part)
+				}
+				return values, resolved
+			}
+		}
+	case "Fn::FindInMap":
+		if len(args) == 3 {
+			if value, ok := findInMap(r.mappings, data.ToString(args[0]), data.ToString(args[1]), data.ToString(args[2])); ok {
+				return value, resolved
+			}
+			return original, r.unresolved(path, fn, "refers to a missing mapping")
+		}
+	case "Fn::Base64":
+		if s, ok := resolvedArg.(string); ok {
+			return base64.StdEncoding.EncodeToString([]byte(s)), resolved
+		}
+	case "Fn::Length":
+		if values, ok := resolvedArg.([]interface{}); ok {
+			return float64(len(values)), resolved
+		}
+	case "Fn::ToJsonString":
+		if b, err := json.Marshal(resolvedArg); err == nil {
+			return string(b), resolved
+		}
+	}
+	return original, r.unresolved(path, fn, "has invalid arguments")
+}
+
+func getAttParts(arg interface{}) []string {
+	switch t := arg.(type) {
+	case string:
+		return strings.SplitN(t, ".", 2)
+	case []interface{}:
+		var parts []string
+		for _, p := range t {
+			if s, ok := p.(string); ok {
+				parts = append(parts, s)
+			}
+		}
+		return parts
+	}
+	return nil
+}
+
+// ref resolves a Ref, or a GetAtt when attr is set, to a parameter or a resource.
+func (r *intrinsicResolver) ref(path string, name string, attr string, original interface{}) (interface{}, resolution) {
+	if attr == "" {
+		if name == "AWS::NoValue" {
+			return noValue{}, resolved
+		}
+		if value, ok := r.parameters[name]; ok {
+			return value, resolved
+		}
+	}
+	if r.resources[name] {
+		if r.attribute == nil {
+			return original, deferred
+		}
+		if value, ok := r.attribute(name, attr); ok {
+			return value, resolved
+		}
+		if attr != "" {
+			return original, r.unresolved(path, "Fn::GetAtt", fmt.Sprintf("%s has no attribute %q", name, attr))
+		}
+		return original, deferred
+	}
+	if attr != "" {
+		return original, r.unresolved(path, "Fn::GetAtt", fmt.Sprintf("refers to %q which is not a resource in the template", name))
+	}
+	return original, r.unresolved(path, "Ref", fmt.Sprintf("refers to %q which has no value, supply it with --parameter %s=value", name, name))
+}
+
+// sub substitutes the variables in a Fn::Sub string. Variables that refer to resources which don't exist yet are left
+// in the string for apply to substitute.
+func (r *intrinsicResolver) sub(path string, arg interface{}) (interface{}, resolution) {
+	var template string
+	variables := map[string]interface{}{}
+	res := resolved
+	switch t := arg.(type) {
+	case string:
+		template = t
+	case []interface{}:
+		if len(t) == 2 {
+			template, _ = t[0].(string)
+			vars, _ := t[1].(map[string]interface{})
+			for k, v := range vars {
+				value, varRes := r.resolve(path, v)
+				variables[k] = value
+				res = worst(res, varRes)
+			}
+		}
+	}
+	remaining := map[string]interface{}{}
+	out := subVariable.ReplaceAllStringFunc(template, func(match string) string {
+		name := match[2 : len(match)-1]
+		if strings.HasPrefix(name, "!") {
+			return match
+		}
+		var value interface{}
+		var varRes resolution
+		if v, ok := variables[name]; ok {
+			value, varRes = v, resolved
+			if data.IsIntrinsic(v) {
+				varRes = deferred
+			}
+		} else if parts := strings.SplitN(name, ".", 2); len(parts) == 2 && !strings.HasPrefix(name, "AWS::") {
+			value, varRes = r.ref(path, parts[0], parts[1], nil)
+		} else {
+			value, varRes = r.ref(path, name, "", nil)
+		}
+		if varRes == resolved {
+			return data.ToString(value)
+		}
+		res = worst(res, varRes)
+		if v, ok := variables[name]; ok {
+			remaining[name] = v
+		}
+		return match
+	})
+	if res == resolved {
+		return strings.Replace(out, "${!", "${", -1), resolved
+	}
+	if len(remaining) > 0 {
+		return map[string]interface{}{"Fn::Sub": []interface{}{out, remaining}}, res
+	}
+	return map[string]interface{}{"Fn::Sub": out}, res
+}
+
+// condition evaluates a named template condition.
+func (r *intrinsicResolver) condition(path string, name string) (bool, bool) {
+	expr, ok := r.conditions[name]
+	if !ok || r.evaluating[name] {
+		r.unresolved(path, "Condition", fmt.Sprintf("%q is not a valid condition", name))
+		return false, false
+	}
+	if r.evaluating == nil {
+		r.evaluating = map[string]bool{}
+	}
+	r.evaluating[name] = true
+	defer delete(r.evaluating, name)
+	return r.evaluateCondition(path, expr)
+}
+
+func (r *intrinsicResolver) evaluateCondition(path string, expr interface{}) (bool, bool) {
+	m, ok := expr.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		r.unresolved(path, "Condition", "is not a condition function")
+		return false, false
+	}
+	for fn, arg := range m {
+		if fn == "Condition" {
+			name, _ := arg.(string)
+			return r.condition(path, name)
+		}
+		args, _ := arg.([]interface{})
+		switch fn {
+		case "Fn::Equals":
+			if len(args) != 2 {
+				break
+			}
+			a, aRes := r.resolve(path, args[0])
+			b, bRes := r.resolve(path, args[1])
+			if worst(aRes, bRes) != resolved {
+				r.unresolved(path, fn, "compares values that can't be resolved")
+				return false, false
+			}
+			return data.ToString(a) == data.ToString(b), true
+		case "Fn::Not":
+			if len(args) != 1 {
+				break
+			}
+			value, ok := r.evaluateCondition(path, args[0])
+			return !value, ok
+		case "Fn::And", "Fn::Or":
+			result := fn == "Fn::And"
+			for _, a := range args {
+				value, ok := r.evaluateCondition(path, a)
+				if !ok {
+					return false, false
+				}
+				if fn == "Fn::And" {
+					result = result && value
+				} else {
+					result = result || value
+				}
+			}
+			return result, true
+		}
+		r.unresolved(path, fn, "is not a valid condition function")
+	}
+	return false, false
+}
+
+func findInMap(mappings map[string]interface{}, name string, top string, second string) (interface{}, bool) {
+	mapping, ok := mappings[name].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	level, ok := mapping[top].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	value, ok := level[second]
+	return value, ok
+}
+
+// references returns the names of the resources that v refers to with Ref, Fn::GetAtt or Fn::Sub.
+func references(v interface{}, resources map[string]bool) []string {
+	found := map[string]bool{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, c := range t {
+				switch k {
+				case "Ref":
+					if s, ok := c.(string); ok && resources[s] {
+						found[s] = true
+					}
+				case "Fn::GetAtt":
+					if parts := getAttParts(c); len(parts) > 0 && resources[parts[0]] {
+						found[parts[0]] = true
+					}
+				case "Fn::Sub":
+					s, _ := c.(string)
+					if l, ok := c.([]interface{}); ok && len(l) > 0 {
+						s, _ = l[0].(string)
+					}
+					for _, match := range subVariable.FindAllStringSubmatch(s, -1) {
+						name := strings.SplitN(match[1], ".", 2)[0]
+						if resources[name] {
+							found[name] = true
+						}
+					}
+				}
+				walk(c)
+			}
+		case []interface{}:
+			for _, c := range t {
+				walk(c)
+			}
+		}
+	}
+	walk(v)
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,23 +1,149 @@
 package crudl
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
 	"github.com/fatih/color"
+	"github.com/jaymccon/cloudctl/data"
 	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
 	"github.com/rodaine/table"
+	"strings"
+	"time"
 )
 
-func ListResource(typeName string) {
-	resources, err := awsProvider.ListResource(typeName)
+// ListCacheTTL is how long listed resources are reused for completions and identifier lookups.
+var ListCacheTTL = 30 * time.Second
+
+// ListFormats are the output formats supported by ListResource, name prints one identifier per line for piping into
+// other commands' --filename -.
+var ListFormats = []string{"table", "name", "json"}
+
+func ListResource(ctx context.Context, typeName string, where []string, output string) {
+	if output == "" {
+		output = "table"
+	}
+	if !contains(ListFormats, output) {
+		ReportError(Invalidf("unsupported output %q for list, supported outputs are %s", output, ListFormats))
+		return
+	}
+	resources, err := awsProvider.ListResource(ctx, typeName)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	cacheList(ctx, typeName, *resources)
+	filtered, err := filterResources(*resources, where)
 	if err != nil {
-		fmt.Printf("ERROR: %q\n", err.Error())
+		ReportError(err)
 		return
 	}
-	headers := append([]interface{}{"Identifier"}, GetTableHeaders(*resources)...)
+	switch output {
+	case "name":
+		for _, r := range filtered {
+			fmt.Println(*r.Identifier)
+		}
+		return
+	case "json":
+		for _, r := range filtered {
+			props := map[string]interface{}{}
+			if r.Properties != nil {
+				if err := json.Unmarshal([]byte(*r.Properties), &props); err != nil {
+					ReportError(fmt.Errorf("parsing the properties of %s: %w", *r.Identifier, err))
+					continue
+				}
+			}
+			b, _ := json.Marshal(map[string]interface{}{"Identifier": *r.Identifier, "Properties": props})
+			fmt.Println(string(b))
+		}
+		return
+	}
+	headers := append([]interface{}{"Identifier"}, GetTableHeaders(filtered)...)
 	tbl := table.New(headers...)
 	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
 	columnFmt := color.New(color.FgYellow).SprintfFunc()
 	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
-	createRows(tbl, *resources, headers)
+	createRows(tbl, filtered, headers)
 	tbl.Print()
 }
+
+// ListResourcesCached lists resources, reusing a list made in the same account and region within the last ListCacheTTL
+// if there is one. It is used where a slightly stale list is acceptable, like shell completion.
+func ListResourcesCached(ctx context.Context, typeName string) (*[]types.ResourceDescription, error) {
+	scope, err := listScope(ctx)
+	if err == nil {
+		if resources, ok := data.GetCachedList(scope, typeName, ListCacheTTL); ok {
+			return resources, nil
+		}
+	}
+	resources, err := awsProvider.ListResource(ctx, typeName)
+	if err != nil {
+		return nil, err
+	}
+	if scope != "" {
+		_ = data.PutCachedList(scope, typeName, *resources)
+	}
+	return resources, nil
+}
+
+// cacheList keeps a list for ListResourcesCached, lists are only cached when their scope is known.
+func cacheList(ctx context.Context, typeName string, resources []types.ResourceDescription) {
+	if scope, err := listScope(ctx); err == nil {
+		_ = data.PutCachedList(scope, typeName, resources)
+	}
+}
+
+// listScope identifies where lists are made, the region, the account and the service role the handlers run as, so
+// that a list isn't reused for a command aimed at another account or region. Accounts are looked up once per access
+// key.
+func listScope(ctx context.Context) (string, error) {
+	region, accessKeyId, err := awsProvider.Caller(ctx)
+	if err != nil {
+		return "", err
+	}
+	account, ok := data.GetCachedAccount(accessKeyId)
+	if !ok {
+		account, err = awsProvider.AccountId(ctx)
+		if err != nil {
+			return "", err
+		}
+		_ = data.PutCachedAccount(accessKeyId, account)
+	}
+	return strings.Join([]string{region, account, awsProvider.ServiceRoleArn}, "|"), nil
+}
+
+// filterResources returns the resources whose properties match every Path=value in where.
+func filterResources(resources []types.ResourceDescription, where []string) ([]types.ResourceDescription, error) {
+	if len(where) == 0 {
+		return resources, nil
+	}
+	conditions := map[string]string{}
+	for _, w := range where {
+		path, value, err := data.ParseAssignment(w)
+		if err != nil {
+			return nil, err
+		}
+		conditions[path] = value
+	}
+	var filtered []types.ResourceDescription
+	for _, r := range resources {
+		var props map[string]interface{}
+		err := json.Unmarshal([]byte(*r.Properties), &props)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the properties of %s: %w", *r.Identifier, err)
+		}
+		matched := true
+		for path, value := range conditions {
+			v, ok := data.GetPath(props, path)
+			if !ok || data.ToString(v) != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
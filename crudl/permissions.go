@@ -0,0 +1,228 @@
+package crudl
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jaymccon/cloudctl/data"
+	"os"
+	"sort"
+	"strings"
+)
+
+// PolicyVerbs are the verbs that a policy can be generated for, they match the handlers in the schemas.
+var PolicyVerbs = []string{"create", "read", "update", "delete", "list"}
+
+// policyApiActions are the cloud control api actions each verb calls. They don't support resource level permissions,
+// so they are always allowed on every resource.
+var policyApiActions = map[string][]string{
+	"create": {"cloudformation:CreateResource", "cloudformation:GetResourceRequestStatus"},
+	"read":   {"cloudformation:GetResource", "cloudformation:ListResources"},
+	"update": {"cloudformation:GetResource", "cloudformation:UpdateResource", "cloudformation:GetResourceRequestStatus", "cloudformation:ListResources"},
+	"delete": {"cloudformation:GetResource", "cloudformation:DeleteResource", "cloudformation:GetResourceRequestStatus", "cloudformation:ListResources"},
+	"list":   {"cloudformation:ListResources"},
+}
+
+// resolvingVerbs resolve references to identifiers, which reads the resource and lists the type's resources for
+// selectors and for identifiers that aren't found, so they need the read and list handlers' permissions as well as
+// their own.
+var resolvingVerbs = map[string]bool{"read": true, "update": true, "delete": true}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Sid      string      `json:"Sid"`
+	Effect   string      `json:"Effect"`
+	Action   []string    `json:"Action"`
+	Resource interface{} `json:"Resource"`
+}
+
+// policyGrant is the verbs needed on a type, and the arns of the resources they are needed on if they are all known.
+type policyGrant struct {
+	verbs map[string]bool
+	arns  []string
+	// unscoped is set when any of the type's resources doesn't have a known arn
+	unscoped bool
+}
+
+// Permissions prints an IAM policy that allows verbs on typeNames, and whatever applying the manifests at
+// manifestPath needs. The policy unions the permissions of the schemas' handlers. With scope, the handler actions of a
+// type are limited to its resources' arns when every manifest of the type has one, either as its identifier or as an
+// Arn property. Handlers often need actions on other resources too, so scoped policies should be checked before use.
+func Permissions(typeNames []string, verbs []string, manifestPath string, scope bool) {
+	if len(typeNames) == 0 && manifestPath == "" {
+		ReportError(Invalidf("permissions needs resource types or a manifest"))
+		return
+	}
+	if len(verbs) == 0 {
+		verbs = PolicyVerbs
+	}
+	for _, v := range verbs {
+		if !contains(PolicyVerbs, v) {
+			ReportError(Invalidf("unsupported verb %q, supported verbs are %s", v, strings.Join(PolicyVerbs, ", ")))
+			return
+		}
+	}
+	grants := map[string]*policyGrant{}
+	grant := func(typeName string, verb string) *policyGrant {
+		g, ok := grants[typeName]
+		if !ok {
+			g = &policyGrant{verbs: map[string]bool{}}
+			grants[typeName] = g
+		}
+		g.verbs[verb] = true
+		return g
+	}
+	for _, typeName := range typeNames {
+		for _, v := range verbs {
+			grant(typeName, v).unscoped = true
+		}
+	}
+	if manifestPath != "" {
+		if manifestPath == "-" {
+			stdinConsumed = true
+		}
+		manifests, err := data.ReadManifests(manifestPath)
+		if err != nil {
+			ReportError(Invalid(err))
+			return
+		}
+		for _, m := range manifests {
+			// apply reads each resource after creating or updating it, and updates manifests without an identifier
+			// instead of creating them when the resource already exists
+			verbs := []string{"update"}
+			if m.Identifier == "" {
+				verbs = append(verbs, "create")
+			}
+			grant(m.TypeName, "read")
+			arn := manifestArn(m)
+			for _, verb := range verbs {
+				g := grant(m.TypeName, verb)
+				if arn == "" {
+					g.unscoped = true
+				} else {
+					g.arns = append(g.arns, arn)
+				}
+			}
+		}
+	}
+	policy, err := buildPolicy(grants, scope)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	b, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	fmt.Println(string(b))
+}
+
+// manifestArn returns the arn of the manifest's resource, if its identifier is an arn or it has an Arn property.
+func manifestArn(m data.Manifest) string {
+	if strings.HasPrefix(m.Identifier, "arn:") {
+		return m.Identifier
+	}
+	if arn, ok := m.Properties["Arn"].(string); ok && strings.HasPrefix(arn, "arn:") {
+		return arn
+	}
+	return ""
+}
+
+func buildPolicy(grants map[string]*policyGrant, scope bool) (*policyDocument, error) {
+	var typeNames []string
+	for typeName := range grants {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+	api := map[string]bool{}
+	unscoped := map[string]bool{}
+	scoped := map[string]map[string]bool{}
+	for _, typeName := range typeNames {
+		g := grants[typeName]
+		schema, err := data.GetSchema(typeName)
+		if err != nil {
+			return nil, Invalidf("%s is not a cached resource type", typeName)
+		}
+		actions := map[string]bool{}
+		for verb := range g.verbs {
+			for _, a := range policyApiActions[verb] {
+				api[a] = true
+			}
+			for _, a := range schema.Handlers.Permissions(verb) {
+				actions[a] = true
+			}
+			if resolvingVerbs[verb] {
+				for _, a := range schema.Handlers.Permissions("read") {
+					actions[a] = true
+				}
+				// listing isn't scoped to resources
+				for _, a := range schema.Handlers.Permissions("list") {
+					unscoped[a] = true
+				}
+			}
+		}
+		if !scope || g.unscoped {
+			for a := range actions {
+				unscoped[a] = true
+			}
+			continue
+		}
+		scoped[typeName] = actions
+	}
+	policy := &policyDocument{Version: "2012-10-17"}
+	policy.Statement = append(policy.Statement, policyStatement{
+		Sid:      "CloudControl",
+		Effect:   "Allow",
+		Action:   sortedSet(api, nil),
+		Resource: "*",
+	})
+	if len(unscoped) > 0 {
+		policy.Statement = append(policy.Statement, policyStatement{
+			Sid:      "ResourceHandlers",
+			Effect:   "Allow",
+			Action:   sortedSet(unscoped, nil),
+			Resource: "*",
+		})
+	}
+	for _, typeName := range typeNames {
+		actions, ok := scoped[typeName]
+		if !ok {
+			continue
+		}
+		// actions that are already allowed on every resource don't need repeating
+		remaining := sortedSet(actions, unscoped)
+		if len(remaining) == 0 {
+			continue
+		}
+		arns := map[string]bool{}
+		for _, arn := range grants[typeName].arns {
+			arns[arn] = true
+		}
+		policy.Statement = append(policy.Statement, policyStatement{
+			Sid:      nonAlphanumeric.ReplaceAllString(typeName, ""),
+			Effect:   "Allow",
+			Action:   remaining,
+			Resource: sortedSet(arns, nil),
+		})
+	}
+	if len(unscoped) == 0 && len(scoped) == 0 {
+		fmt.Fprintln(os.Stderr, "WARNING: the schemas don't list any handler permissions, only the cloud control actions are included")
+	}
+	return policy, nil
+}
+
+// sortedSet returns the values in set that aren't in exclude, sorted.
+func sortedSet(set map[string]bool, exclude map[string]bool) []string {
+	var values []string
+	for v := range set {
+		if !exclude[v] {
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
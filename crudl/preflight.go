@@ -0,0 +1,96 @@
+package crudl
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CheckPermissions simulates the IAM permissions of mutating operations before they are made, so that missing
+// permissions are found before a resource is left half created.
+var CheckPermissions bool
+
+// NewPermissionSimulator returns the simulator the permission preflight uses, the IAM policy simulator unless it has
+// been replaced, eg. with an awsProvider.StaticSimulator.
+var NewPermissionSimulator = func(ctx context.Context) (awsProvider.PermissionSimulator, error) {
+	return awsProvider.NewIamSimulator(ctx)
+}
+
+// cloudControlActions are the cloud control api actions that each verb calls, they are needed as well as the actions
+// the resource's handler calls.
+var cloudControlActions = map[string][]string{
+	"create": {"cloudformation:CreateResource", "cloudformation:GetResourceRequestStatus"},
+	"update": {"cloudformation:GetResource", "cloudformation:UpdateResource", "cloudformation:GetResourceRequestStatus"},
+	"delete": {"cloudformation:DeleteResource", "cloudformation:GetResourceRequestStatus"},
+}
+
+// permissionCheck is an operation whose permissions are simulated by preflight.
+type permissionCheck struct {
+	verb     string
+	typeName string
+}
+
+// preflight returns an error listing the actions that the current principal is missing for checks, or nil if it has
+// them all or CheckPermissions isn't set. If the simulation itself fails a warning is printed and the operations go
+// ahead, the credentials may be allowed to manage resources without being allowed to simulate policies.
+func preflight(ctx context.Context, checks ...permissionCheck) error {
+	if !CheckPermissions {
+		return nil
+	}
+	simulator, err := NewPermissionSimulator(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: skipping the permission check: %s\n", err.Error())
+		return nil
+	}
+	seen := map[permissionCheck]bool{}
+	var problems []string
+	var missing []string
+	for _, c := range checks {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		actions := append([]string{}, cloudControlActions[c.verb]...)
+		if awsProvider.ServiceRoleArn != "" {
+			// handlers run as the service role, the caller only needs to be allowed to pass it
+			actions = append(actions, "iam:PassRole")
+		} else {
+			actions = append(actions, requiredPermissions(c.typeName, c.verb)...)
+		}
+		m, err := simulator.MissingActions(ctx, actions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: skipping the permission check: %s\n", err.Error())
+			return nil
+		}
+		if len(m) > 0 {
+			problems = append(problems, fmt.Sprintf("%s %s: %s", c.verb, c.typeName, strings.Join(m, ", ")))
+			missing = append(missing, m...)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return &Error{
+		Message:             "the current credentials are missing IAM permissions for\n  " + strings.Join(problems, "\n  "),
+		ExitCode:            ExitValidation,
+		HandlerErrorCode:    string(types.HandlerErrorCodeAccessDenied),
+		Hint:                "nothing was changed, add the missing actions to the principal's policies and try again",
+		RequiredPermissions: dedupe(missing),
+	}
+}
+
+// dedupe removes repeated values from a sorted slice.
+func dedupe(sorted []string) []string {
+	var unique []string
+	for i, v := range sorted {
+		if i == 0 || v != sorted[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
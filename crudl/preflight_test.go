@@ -0,0 +1,125 @@
+package crudl
+
+import (
+	"context"
+	"errors"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const widgetSchema = `{
+  "typeName": "AWS::Test::Widget",
+  "description": "A resource type for testing the permission preflight.",
+  "properties": {"Name": {"type": "string"}},
+  "primaryIdentifier": ["/properties/Name"],
+  "handlers": {
+    "create": {"permissions": ["test:CreateWidget", "test:TagWidget"]},
+    "read": {"permissions": ["test:DescribeWidget"]},
+    "update": {"permissions": ["test:UpdateWidget"]},
+    "delete": {"permissions": ["test:DeleteWidget"]},
+    "list": {"permissions": ["test:ListWidgets"]}
+  }
+}`
+
+// withSimulator runs the preflight against a cache holding widgetSchema, with the IAM policy simulator replaced by a
+// StaticSimulator that allows allowed.
+func withSimulator(t *testing.T, allowed []string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "aws-test-widget.json"), []byte(widgetSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := data.ImportSchemas(dir); err != nil {
+		t.Fatal(err)
+	}
+	newSimulator, checkPermissions, serviceRole := NewPermissionSimulator, CheckPermissions, awsProvider.ServiceRoleArn
+	t.Cleanup(func() {
+		NewPermissionSimulator, CheckPermissions, awsProvider.ServiceRoleArn = newSimulator, checkPermissions, serviceRole
+	})
+	NewPermissionSimulator = func(ctx context.Context) (awsProvider.PermissionSimulator, error) {
+		return awsProvider.StaticSimulator{Allowed: allowed}, nil
+	}
+	CheckPermissions = true
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     []string
+		serviceRole string
+		check       permissionCheck
+		missing     []string
+	}{
+		{
+			name:    "allowed",
+			allowed: []string{"cloudformation:CreateResource", "cloudformation:GetResourceRequestStatus", "test:CreateWidget", "test:TagWidget"},
+			check:   permissionCheck{verb: "create", typeName: "AWS::Test::Widget"},
+		},
+		{
+			name:    "missing",
+			allowed: []string{"cloudformation:CreateResource", "cloudformation:GetResourceRequestStatus", "test:CreateWidget"},
+			check:   permissionCheck{verb: "create", typeName: "AWS::Test::Widget"},
+			missing: []string{"test:TagWidget"},
+		},
+		{
+			name:    "wildcard",
+			allowed: []string{"cloudformation:*", "test:*Widget"},
+			check:   permissionCheck{verb: "delete", typeName: "AWS::Test::Widget"},
+		},
+		{
+			name:    "wildcard missing",
+			allowed: []string{"cloudformation:*Resource", "test:?escribe*"},
+			check:   permissionCheck{verb: "delete", typeName: "AWS::Test::Widget"},
+			missing: []string{"cloudformation:GetResourceRequestStatus", "test:DeleteWidget"},
+		},
+		{
+			name:        "service role",
+			allowed:     []string{"cloudformation:*", "iam:PassRole"},
+			serviceRole: "arn:aws:iam::123456789012:role/cloudctl",
+			check:       permissionCheck{verb: "update", typeName: "AWS::Test::Widget"},
+		},
+		{
+			name:        "service role not passable",
+			allowed:     []string{"cloudformation:*", "test:*"},
+			serviceRole: "arn:aws:iam::123456789012:role/cloudctl",
+			check:       permissionCheck{verb: "update", typeName: "AWS::Test::Widget"},
+			missing:     []string{"iam:PassRole"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSimulator(t, tt.allowed)
+			awsProvider.ServiceRoleArn = tt.serviceRole
+			err := preflight(context.Background(), tt.check)
+			if len(tt.missing) == 0 {
+				if err != nil {
+					t.Fatalf("preflight() = %v, want nil", err)
+				}
+				return
+			}
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("preflight() = %v, want an *Error", err)
+			}
+			if e.ExitCode != ExitValidation {
+				t.Errorf("ExitCode = %d, want %d", e.ExitCode, ExitValidation)
+			}
+			if !reflect.DeepEqual(e.RequiredPermissions, tt.missing) {
+				t.Errorf("RequiredPermissions = %q, want %q", e.RequiredPermissions, tt.missing)
+			}
+		})
+	}
+}
+
+func TestPreflightDisabled(t *testing.T) {
+	withSimulator(t, nil)
+	CheckPermissions = false
+	if err := preflight(context.Background(), permissionCheck{verb: "create", typeName: "AWS::Test::Widget"}); err != nil {
+		t.Fatalf("preflight() = %v, want nil when permissions aren't checked", err)
+	}
+}
@@ -0,0 +1,153 @@
+package crudl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ProtectedTypes are the resource types that hold data which is lost when they are deleted. Deleting one needs its
+// identifier typed at the prompt rather than a y. Types may use * wildcards, eg. AWS::RDS::*.
+var ProtectedTypes = []string{
+	"AWS::RDS::DBInstance",
+	"AWS::RDS::DBCluster",
+	"AWS::DynamoDB::Table",
+	"AWS::DynamoDB::GlobalTable",
+	"AWS::EFS::FileSystem",
+	"AWS::EC2::Volume",
+}
+
+// ProtectedTags protect the resources with a tag whose key matches, ignoring case, and whose value matches the
+// pattern, which may use * wildcards.
+var ProtectedTags = map[string]string{"environment": "prod*"}
+
+// protectedResource is a resource that can only be deleted by typing its identifier.
+type protectedResource struct {
+	typeName   string
+	identifier string
+	reason     string
+}
+
+// protectedResources returns the resources that are protected by ProtectedTypes or ProtectedTags, in the order they
+// were given. Resources are only read when their type isn't protected and there are tags to check.
+func protectedResources(ctx context.Context, cc *cloudcontrol.Client, resources []*resourceNode) ([]protectedResource, error) {
+	var protected []protectedResource
+	reasons := map[string]string{}
+	byType := map[string][]string{}
+	var typeNames []string
+	for _, r := range resources {
+		if matchesAny(ProtectedTypes, r.TypeName) {
+			reasons[r.key()] = r.TypeName + " is a protected type"
+			continue
+		}
+		if len(ProtectedTags) == 0 {
+			continue
+		}
+		if _, ok := byType[r.TypeName]; !ok {
+			typeNames = append(typeNames, r.TypeName)
+		}
+		byType[r.TypeName] = append(byType[r.TypeName], r.Identifier)
+	}
+	for _, typeName := range typeNames {
+		ids := byType[typeName]
+		props, errs := awsProvider.AsyncCcGetResources(ctx, *cc, typeName, ids)
+		for i, id := range ids {
+			if errs[i] != nil {
+				// the delete reports resources that are already gone
+				if errors.Is(errs[i], awsProvider.ErrNotFound) {
+					continue
+				}
+				return nil, resourceError(errs[i], typeName, id)
+			}
+			properties := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(*props[i]), &properties); err != nil {
+				return nil, err
+			}
+			if tag := protectedTag(properties); tag != "" {
+				node := resourceNode{TypeName: typeName, Identifier: id}
+				reasons[node.key()] = "it is tagged " + tag
+			}
+		}
+	}
+	for _, r := range resources {
+		if reason, ok := reasons[r.key()]; ok {
+			protected = append(protected, protectedResource{typeName: r.TypeName, identifier: r.Identifier, reason: reason})
+		}
+	}
+	return protected, nil
+}
+
+// protectedTag returns the first of props' tags, as Key=Value, that matches ProtectedTags.
+func protectedTag(props map[string]interface{}) string {
+	tags := resourceTags(props)
+	var keys []string
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for protectedKey, pattern := range ProtectedTags {
+			if strings.EqualFold(k, protectedKey) && matchesAny([]string{pattern}, tags[k]) {
+				return k + "=" + tags[k]
+			}
+		}
+	}
+	return ""
+}
+
+// matchesAny reports whether s matches any of patterns, ignoring case. A * in a pattern matches any characters.
+func matchesAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		expr := "(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*") + "$"
+		if ok, _ := regexp.MatchString(expr, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// printProtected lists the protected resources in a deletion plan.
+func printProtected(protected []protectedResource) {
+	if len(protected) == 0 {
+		return
+	}
+	fmt.Printf("%d of these resources are protected, their identifiers have to be typed to delete them:\n", len(protected))
+	for _, p := range protected {
+		fmt.Printf("  %s %s, %s\n", p.typeName, p.identifier, p.reason)
+	}
+}
+
+// confirmProtected asks for the identifier of each protected resource to be typed, and reports whether they all were.
+func confirmProtected(protected []protectedResource) bool {
+	for _, p := range protected {
+		answer, err := Prompt(fmt.Sprintf("Type the identifier of %s %s to delete it", p.typeName, p.identifier))
+		if err != nil {
+			ReportError(err)
+			return false
+		}
+		if answer != p.identifier {
+			fmt.Printf("%q doesn't match the identifier.\n", answer)
+			return false
+		}
+	}
+	return true
+}
+
+// protectedError is the error for deleting protected resources without prompts, they can't be confirmed.
+func protectedError(protected []protectedResource) *Error {
+	var names []string
+	for _, p := range protected {
+		names = append(names, fmt.Sprintf("%s %s (%s)", p.typeName, p.identifier, p.reason))
+	}
+	return &Error{
+		Message:  "protected resources can't be deleted without a prompt:\n  " + strings.Join(names, "\n  "),
+		ExitCode: ExitValidation,
+		Hint:     "nothing was deleted, run without --no-prompt to confirm the identifiers, or change protected_types and protected_tags in the config file",
+	}
+}
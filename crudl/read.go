@@ -1,14 +1,86 @@
 package crudl
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/alecthomas/chroma/quick"
 	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"gopkg.in/yaml.v3"
+	"os"
 )
 
-func ReadResource(typeName string, id string, noPrompts bool, async bool) {
-	cc, err := awsProvider.NewCcClient()
+// ReadFormats are the output formats supported by ReadResources.
+var ReadFormats = []string{"yaml", "json"}
+
+// ReadResources prints the properties of resources as a stream of documents, yaml documents separated by --- or one
+// json object per line. refs are resolved with ResolveIdentifier, ids are read as they are. Resources are read
+// concurrently and printed in the order they were given.
+func ReadResources(ctx context.Context, typeName string, refs []string, ids []string, output string, noPrompts bool) {
+	if output == "" {
+		output = "yaml"
+	}
+	if !contains(ReadFormats, output) {
+		ReportError(Invalidf("unsupported output %q for read, supported outputs are %s", output, ReadFormats))
+		return
+	}
+	ids = resolveIdentifiers(ctx, typeName, refs, ids, noPrompts)
+	if len(ids) == 0 {
+		return
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	properties, errs := awsProvider.AsyncCcGetResources(ctx, *cc, typeName, ids)
+	for i, props := range properties {
+		if errs[i] != nil {
+			continue
+		}
+		err = printDocument(*props, output, i > 0)
+		if err != nil {
+			errs[i] = err
+		}
+	}
+	reportBatch(typeName, ids, errs)
+}
+
+// printDocument prints a resource's json properties in the output format. yaml is highlighted when stdout is a
+// terminal.
+func printDocument(properties string, output string, separate bool) error {
+	jsonProps := map[string]interface{}{}
+	err := json.Unmarshal([]byte(properties), &jsonProps)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
+		return err
+	}
+	if output == "json" {
+		b, err := json.Marshal(jsonProps)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	yamlDoc, err := yaml.Marshal(jsonProps)
+	if err != nil {
+		return err
+	}
+	if separate {
+		fmt.Println("---")
+	}
+	if !awsProvider.IsTerminal(os.Stdout) {
+		_, err = os.Stdout.Write(yamlDoc)
+		return err
+	}
+	return quick.Highlight(os.Stdout, string(yamlDoc), "yaml", "terminal16m", "pygments")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
-	awsProvider.AsyncCcReadResource(*cc, typeName, id, async)
+	return false
 }
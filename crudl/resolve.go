@@ -0,0 +1,238 @@
+package crudl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"strings"
+)
+
+const tagSelectorPrefix = "tag:"
+
+// ResolveIdentifier turns a reference to a resource into its primary identifier. References can be:
+//
+//   - a primary identifier
+//   - the value of one of the schema's additionalIdentifiers
+//   - name=<name>, matching a Name tag, a Name property or a property named after the resource, eg. BucketName
+//   - tag:<key>=<value>, matching a tag
+//   - <Path>=<value>, matching a property, where Path is a property of the type
+//   - part of a primary identifier, which always has to be chosen at a prompt
+//
+// References that aren't selectors are read first, the type's resources are only listed when there is no resource
+// with that identifier. When several resources match the user is asked to choose one, unless noPrompts is set.
+func ResolveIdentifier(ctx context.Context, typeName string, ref string, noPrompts bool) (string, error) {
+	schema, err := data.GetSchema(typeName)
+	if err != nil {
+		return "", err
+	}
+	if isSelector(*schema, ref) {
+		match, err := newSelector(*schema, ref)
+		if err != nil {
+			return "", Invalid(err)
+		}
+		resources, err := ListResourcesCached(ctx, typeName)
+		if err != nil {
+			return "", fmt.Errorf("listing %s to resolve %q: %w", typeName, ref, err)
+		}
+		matches := filter(*resources, match)
+		if len(matches) == 0 {
+			return "", notFoundf("no %s resources match %q", typeName, ref)
+		}
+		return chooseMatch(fmt.Sprintf("%q matches %d %s resources", ref, len(matches), typeName), matches, len(matches) > 1, noPrompts)
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	_, err = awsProvider.GetResource(ctx, cc, typeName, ref)
+	if !errors.Is(err, awsProvider.ErrNotFound) {
+		// other failures are left for the operation to report
+		return ref, nil
+	}
+	resources, listErr := ListResourcesCached(ctx, typeName)
+	if listErr != nil {
+		return "", resourceError(err, typeName, ref)
+	}
+	if matches := filter(*resources, additionalIdentifierMatcher(*schema, ref)); len(matches) > 0 {
+		return chooseMatch(fmt.Sprintf("%q matches %d %s resources", ref, len(matches), typeName), matches, len(matches) > 1, noPrompts)
+	}
+	matches := filter(*resources, func(id string, props map[string]interface{}) bool {
+		return strings.Contains(strings.ToLower(id), strings.ToLower(ref))
+	})
+	if len(matches) == 0 {
+		return "", resourceError(err, typeName, ref)
+	}
+	// a partial match is a guess, so it is never used without being chosen
+	return chooseMatch(fmt.Sprintf("%q is not a %s identifier, it is part of %d", ref, typeName, len(matches)), matches, true, noPrompts)
+}
+
+// chooseMatch returns the identifier of the only match, or the one the user chooses when there are several or prompt
+// is set. Choices can't be made when prompts are disabled, so they are an error.
+func chooseMatch(question string, matches []types.ResourceDescription, prompt bool, noPrompts bool) (string, error) {
+	if !prompt {
+		return *matches[0].Identifier, nil
+	}
+	headers := append([]interface{}{"Identifier"}, GetTableHeaders(matches)...)
+	var options []string
+	for _, r := range matches {
+		var cols []string
+		for _, c := range GetRow(r, headers) {
+			cols = append(cols, c.(string))
+		}
+		options = append(options, strings.TrimSpace(strings.Join(cols, " ")))
+	}
+	if noPrompts {
+		return "", Invalidf("%s, use the full identifier:\n  %s", question, strings.Join(options, "\n  "))
+	}
+	choice, err := choose(question, options)
+	if err != nil {
+		return "", err
+	}
+	return *matches[choice].Identifier, nil
+}
+
+type matcher func(id string, props map[string]interface{}) bool
+
+// isSelector reports whether ref selects resources rather than being an identifier. Identifiers can contain =, so
+// only tag:, name= and Path=value where Path is one of the schema's properties are selectors.
+func isSelector(schema data.CfnSchema, ref string) bool {
+	if strings.HasPrefix(ref, tagSelectorPrefix) {
+		return true
+	}
+	path, _, err := data.ParseAssignment(ref)
+	if err != nil {
+		return false
+	}
+	if strings.ToLower(path) == "name" {
+		return true
+	}
+	_, ok := schema.Property(path)
+	return ok
+}
+
+func newSelector(schema data.CfnSchema, ref string) (matcher, error) {
+	if strings.HasPrefix(ref, tagSelectorPrefix) {
+		key, value, err := data.ParseAssignment(strings.TrimPrefix(ref, tagSelectorPrefix))
+		if err != nil {
+			return nil, err
+		}
+		return func(id string, props map[string]interface{}) bool {
+			v, ok := tagValue(props, key)
+			return ok && v == value
+		}, nil
+	}
+	path, value, err := data.ParseAssignment(ref)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(path) == "name" {
+		parts := strings.Split(schema.TypeName, "::")
+		resource := parts[len(parts)-1]
+		return func(id string, props map[string]interface{}) bool {
+			if v, ok := tagValue(props, "Name"); ok && v == value {
+				return true
+			}
+			for _, p := range []string{"Name", resource + "Name"} {
+				if v, ok := props[p]; ok && data.ToString(v) == value {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if _, ok := schema.Property(path); !ok {
+		return nil, errors.New(fmt.Sprintf("%s does not have a property %q", schema.TypeName, path))
+	}
+	return func(id string, props map[string]interface{}) bool {
+		v, ok := data.GetPath(props, path)
+		return ok && data.ToString(v) == value
+	}, nil
+}
+
+// additionalIdentifierMatcher matches resources where ref is the value of one of the schema's single property
+// additional identifiers, eg. the Arn of an IAM role.
+func additionalIdentifierMatcher(schema data.CfnSchema, ref string) matcher {
+	return func(id string, props map[string]interface{}) bool {
+		for _, identifier := range schema.AdditionalIdentifiers {
+			if len(identifier) != 1 {
+				continue
+			}
+			path := strings.Replace(strings.TrimPrefix(identifier[0], "/properties/"), "/", ".", -1)
+			if v, ok := data.GetPath(props, path); ok && data.ToString(v) == ref {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func filter(resources []types.ResourceDescription, match matcher) []types.ResourceDescription {
+	var matches []types.ResourceDescription
+	for _, r := range resources {
+		props := map[string]interface{}{}
+		if r.Properties != nil {
+			err := json.Unmarshal([]byte(*r.Properties), &props)
+			if err != nil {
+				continue
+			}
+		}
+		if match(*r.Identifier, props) {
+			matches = append(matches, r)
+		}
+	}
+	return matches
+}
+
+// tagValue returns the value of a tag.
+func tagValue(props map[string]interface{}, key string) (string, bool) {
+	v, ok := resourceTags(props)[key]
+	return v, ok
+}
+
+// resourceTags returns a resource's tags, tags can either be a list of Key/Value objects or a map.
+func resourceTags(props map[string]interface{}) map[string]string {
+	values := map[string]string{}
+	switch tags := props["Tags"].(type) {
+	case []interface{}:
+		for _, t := range tags {
+			tag, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			k, kOk := tag["Key"].(string)
+			v, vOk := tag["Value"].(string)
+			if kOk && vOk {
+				values[k] = v
+			}
+		}
+	case map[string]interface{}:
+		for k, v := range tags {
+			if s, ok := v.(string); ok {
+				values[k] = s
+			}
+		}
+	}
+	return values
+}
+
+// resolveIdentifiers resolves each of refs with ResolveIdentifier and returns them after ids, which are used as they
+// are. Refs that can't be resolved are reported and left out, so that the rest of a batch still goes ahead.
+func resolveIdentifiers(ctx context.Context, typeName string, refs []string, ids []string, noPrompts bool) []string {
+	resolved := append([]string{}, ids...)
+	for _, ref := range refs {
+		id, err := ResolveIdentifier(ctx, typeName, ref, noPrompts)
+		if err != nil {
+			ReportError(resourceError(err, typeName, ref))
+			continue
+		}
+		resolved = append(resolved, id)
+	}
+	if len(resolved) > 0 && len(resolved) < len(refs)+len(ids) {
+		setExitCode(ExitPartialFailure)
+	}
+	return resolved
+}
@@ -0,0 +1,133 @@
+package crudl
+
+import (
+	"fmt"
+	"github.com/jaymccon/cloudctl/data"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// writeTerraform writes resources as terraform awscc provider resources, with an import block for each so that
+// terraform adopts the existing resources rather than creating new ones.
+func writeTerraform(w io.Writer, resources []*exportedResource) error {
+	addresses := map[string]string{}
+	for _, r := range resources {
+		addresses[r.LogicalId] = terraformType(r.TypeName) + "." + snakeCase(r.LogicalId)
+	}
+	for _, r := range resources {
+		address := addresses[r.LogicalId]
+		parts := strings.SplitN(address, ".", 2)
+		fmt.Fprintf(w, "resource %q %q {\n", parts[0], parts[1])
+		for _, name := range sortedKeys(r.desired) {
+			def := r.schema.ResolveRef(r.schema.Properties[name])
+			fmt.Fprintf(w, "  %s = %s\n", snakeCase(name), hclValue(*r.schema, r.desired[name], def, addresses, "  "))
+		}
+		fmt.Fprintf(w, "}\n\nimport {\n  to = %s\n  id = %s\n}\n\n", address, hclString(r.Identifier))
+	}
+	return nil
+}
+
+// terraformType returns the awscc resource type for a type name, eg. awscc_ec2_security_group for
+// AWS::EC2::SecurityGroup.
+func terraformType(typeName string) string {
+	parts := strings.Split(typeName, "::")
+	if len(parts) != 3 {
+		return "awscc_" + snakeCase(typeName)
+	}
+	return "awscc_" + snakeCase(parts[1]) + "_" + snakeCase(parts[2])
+}
+
+// snakeCase converts a property name to a terraform attribute name, eg. VpcId to vpc_id and SSESpecification to
+// sse_specification.
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// hclValue renders v as HCL. Objects with properties in the schema become nested attributes with snake case names,
+// free form objects like policy documents are written with jsonencode and keep their keys.
+func hclValue(schema data.CfnSchema, v interface{}, def map[string]interface{}, addresses map[string]string, indent string) string {
+	switch t := v.(type) {
+	case exportRef:
+		attribute := "id"
+		if t.Attribute != "" {
+			attribute = snakeCase(t.Attribute)
+		}
+		return addresses[t.LogicalId] + "." + attribute
+	case map[string]interface{}:
+		children, ok := def["properties"].(map[string]interface{})
+		if !ok {
+			return "jsonencode(" + hclLiteral(t, indent) + ")"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range sortedKeys(t) {
+			b.WriteString(fmt.Sprintf("%s  %s = %s\n", indent, snakeCase(k), hclValue(schema, t[k], schema.ResolveRef(children[k]), addresses, indent+"  ")))
+		}
+		b.WriteString(indent + "}")
+		return b.String()
+	case []interface{}:
+		items := schema.ResolveRef(def["items"])
+		var values []string
+		for _, item := range t {
+			values = append(values, hclValue(schema, item, items, addresses, indent+"  "))
+		}
+		if len(values) == 0 {
+			return "[]"
+		}
+		return "[\n" + indent + "  " + strings.Join(values, ",\n"+indent+"  ") + "\n" + indent + "]"
+	}
+	return hclLiteral(v, indent)
+}
+
+// hclLiteral renders v as an HCL literal without converting object keys.
+func hclLiteral(v interface{}, indent string) string {
+	switch t := v.(type) {
+	case string:
+		return hclString(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range sortedKeys(t) {
+			b.WriteString(fmt.Sprintf("%s  %s = %s\n", indent, hclString(k), hclLiteral(t[k], indent+"  ")))
+		}
+		b.WriteString(indent + "}")
+		return b.String()
+	case []interface{}:
+		var values []string
+		for _, item := range t {
+			values = append(values, hclLiteral(item, indent+"  "))
+		}
+		if len(values) == 0 {
+			return "[]"
+		}
+		return "[\n" + indent + "  " + strings.Join(values, ",\n"+indent+"  ") + "\n" + indent + "]"
+	}
+	return hclString(fmt.Sprint(v))
+}
+
+// hclString quotes s, escaping terraform's template sequences.
+func hclString(s string) string {
+	q := strconv.Quote(s)
+	q = strings.Replace(q, "${", "$${", -1)
+	return strings.Replace(q, "%{", "%%{", -1)
+}
@@ -0,0 +1,56 @@
+package crudl
+
+import (
+	"fmt"
+	"io"
+)
+
+// resourceNode is a live resource and the resources related to it, eg. the resources that depend on it.
+type resourceNode struct {
+	TypeName   string
+	Identifier string
+	// Relation describes how the resource relates to its parent, it is printed in trees but not in labels
+	Relation string
+	Children []*resourceNode
+}
+
+func (n *resourceNode) label() string {
+	return fmt.Sprintf("%s %s", n.TypeName, n.Identifier)
+}
+
+func (n *resourceNode) treeLabel() string {
+	if n.Relation == "" {
+		return n.label()
+	}
+	return fmt.Sprintf("%s (%s)", n.label(), n.Relation)
+}
+
+// key identifies the node's resource, a resource appears in a tree once.
+func (n *resourceNode) key() string {
+	return n.TypeName + "|" + n.Identifier
+}
+
+// Print writes the tree rooted at n, one resource per line.
+func (n *resourceNode) Print(w io.Writer) {
+	fmt.Fprintln(w, n.treeLabel())
+	n.printChildren(w, "")
+}
+
+func (n *resourceNode) printChildren(w io.Writer, indent string) {
+	for i, c := range n.Children {
+		branch, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+		fmt.Fprintln(w, indent+branch+c.treeLabel())
+		c.printChildren(w, indent+next)
+	}
+}
+
+// walk calls f for n and every node below it with the node's depth in the tree.
+func (n *resourceNode) walk(depth int, f func(node *resourceNode, depth int)) {
+	f(n, depth)
+	for _, c := range n.Children {
+		c.walk(depth+1, f)
+	}
+}
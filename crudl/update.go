@@ -0,0 +1,225 @@
+package crudl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jaymccon/cloudctl/data"
+	awsProvider "github.com/jaymccon/cloudctl/providers/aws"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// patchOperation is a single RFC 6902 JSON patch operation.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// UpdateResource applies Path=value assignments to a resource's current state, or opens an editor with the current
+// state if there are none, and sends the difference to cloud control as a patch.
+func UpdateResource(ctx context.Context, typeName string, ref string, sets []string, noPrompts bool, async bool) {
+	id, err := ResolveIdentifier(ctx, typeName, ref, noPrompts)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	schema, err := data.GetSchema(typeName)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	cc, err := awsProvider.NewCcClient(ctx)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	props, err := awsProvider.GetResource(ctx, cc, typeName, id)
+	if err != nil {
+		ReportError(resourceError(err, typeName, id))
+		return
+	}
+	current := map[string]interface{}{}
+	err = json.Unmarshal([]byte(*props), &current)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	removeReadOnly(*schema, current)
+	desired, err := desiredState(ctx, *schema, current, sets)
+	if err != nil {
+		ReportError(Invalid(err))
+		return
+	}
+	patch := diffProperties(current, desired)
+	if len(patch) == 0 {
+		fmt.Println("No changes to apply.")
+		return
+	}
+	if path, ok := changedCreateOnly(*schema, current, desired); ok {
+		ReportError(Invalidf("%s cannot be changed after %s resources are created", path, typeName))
+		return
+	}
+	patchDoc, err := json.Marshal(patch)
+	if err != nil {
+		ReportError(err)
+		return
+	}
+	if err := preflight(ctx, permissionCheck{"update", typeName}); err != nil {
+		ReportError(err)
+		return
+	}
+	if !noPrompts {
+		b, err := json.MarshalIndent(patch, "  ", "  ")
+		if err != nil {
+			ReportError(err)
+			return
+		}
+		fmt.Printf("%s %s will be updated with the patch:\n  %s\n", typeName, id, string(b))
+		if !confirmed(fmt.Sprintf("Are you sure you want to update %s resource with identifier %s", typeName, id)) {
+			fmt.Println("Exiting without updating anything.")
+			return
+		}
+	}
+	err = awsProvider.AsyncCcUpdateResource(ctx, *cc, typeName, id, string(patchDoc), async)
+	if err != nil {
+		ReportError(err)
+	}
+}
+
+func desiredState(ctx context.Context, schema data.CfnSchema, current map[string]interface{}, sets []string) (map[string]interface{}, error) {
+	desired := map[string]interface{}{}
+	if len(sets) == 0 {
+		yamlDoc, err := yaml.Marshal(current)
+		if err != nil {
+			return nil, err
+		}
+		jsonDoc, err := data.Edit(string(yamlDoc), "yml")
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(jsonDoc, &desired)
+		return desired, err
+	}
+	// round trip through json for a deep copy that set can modify
+	b, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &desired)
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		path, value, err := data.ParseAssignment(set)
+		if err != nil {
+			return nil, err
+		}
+		err = schema.SetProperty(desired, path, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return desired, nil
+}
+
+// removeReadOnly removes the schema's read only properties from props, including nested ones such as
+// /properties/Endpoint/Address, so that they aren't sent back in patches that replace their parent property.
+func removeReadOnly(schema data.CfnSchema, props map[string]interface{}) {
+	for _, p := range schema.ReadOnlyProperties {
+		deletePath(props, strings.Split(strings.Replace(p, "/properties/", "", 1), "/"))
+	}
+}
+
+// diffProperties returns the patch that turns current into desired. Top level properties are replaced as a whole.
+func diffProperties(current map[string]interface{}, desired map[string]interface{}) []patchOperation {
+	var patch []patchOperation
+	for k, v := range desired {
+		c, ok := current[k]
+		if !ok {
+			patch = append(patch, patchOperation{Op: "add", Path: "/" + k, Value: v})
+		} else if !reflect.DeepEqual(normalise(c), normalise(v)) {
+			patch = append(patch, patchOperation{Op: "replace", Path: "/" + k, Value: v})
+		}
+	}
+	for k := range current {
+		if _, ok := desired[k]; !ok {
+			patch = append(patch, patchOperation{Op: "remove", Path: "/" + k})
+		}
+	}
+	sort.Slice(patch, func(i, j int) bool {
+		return patch[i].Path < patch[j].Path
+	})
+	return patch
+}
+
+// changedCreateOnly returns the first of the schema's createOnlyProperties whose value differs between current and
+// desired. Create only properties can be nested, eg. /properties/VpcConfig/SubnetIds, and patches replace top level
+// properties as a whole, so the properties' own paths are compared rather than the patch's.
+func changedCreateOnly(schema data.CfnSchema, current map[string]interface{}, desired map[string]interface{}) (string, bool) {
+	for _, p := range schema.CreateOnlyProperties {
+		segments := strings.Split(strings.TrimPrefix(p, "/properties/"), "/")
+		if !reflect.DeepEqual(normalise(pointerValue(current, segments)), normalise(pointerValue(desired, segments))) {
+			return strings.Join(segments, "."), true
+		}
+	}
+	return "", false
+}
+
+// pointerValue returns the value at the json pointer segments, or nil if there isn't one. A * segment collects the
+// values of every item of an array.
+func pointerValue(v interface{}, segments []string) interface{} {
+	if len(segments) == 0 {
+		return v
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return pointerValue(t[segments[0]], segments[1:])
+	case []interface{}:
+		if segments[0] != "*" {
+			i, err := strconv.Atoi(segments[0])
+			if err != nil || i < 0 || i >= len(t) {
+				return nil
+			}
+			return pointerValue(t[i], segments[1:])
+		}
+		var values []interface{}
+		for _, item := range t {
+			values = append(values, pointerValue(item, segments[1:]))
+		}
+		return values
+	}
+	return nil
+}
+
+// normalise round trips a value through json so that values decoded from yaml and json compare equal.
+func normalise(v interface{}) interface{} {
+	var n interface{}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	_ = json.Unmarshal(b, &n)
+	return n
+}
+
+// BuildIdentifier assembles typeName's primary identifier from Name=value parts.
+func BuildIdentifier(typeName string, idParts []string) (string, error) {
+	schema, err := data.GetSchema(typeName)
+	if err != nil {
+		return "", err
+	}
+	parts := map[string]string{}
+	for _, p := range idParts {
+		name, value, err := data.ParseAssignment(p)
+		if err != nil {
+			return "", err
+		}
+		parts[name] = value
+	}
+	return schema.BuildIdentifier(parts)
+}
@@ -7,23 +7,140 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
 	"github.com/jaymccon/cloudctl/data"
 	"github.com/rodaine/table"
-	"log"
+	"gopkg.in/yaml.v3"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 )
 
-func confirm(s string) bool {
-	r := bufio.NewReader(os.Stdin)
-	fmt.Printf("%s [y/n]: ", s)
+// stdinConsumed is set when identifiers or manifests are read from stdin, prompts then read from the terminal instead.
+var stdinConsumed = false
+
+// prompts buffers the input that answers are read from. It is shared between prompts so that answers which were
+// buffered by one prompt, eg. from piped input, are still there for the next.
+var prompts struct {
+	reader *bufio.Reader
+	tty    bool
+}
+
+// promptReader returns the reader that prompts read answers from.
+func promptReader() (*bufio.Reader, error) {
+	if prompts.reader != nil && prompts.tty == stdinConsumed {
+		return prompts.reader, nil
+	}
+	if !stdinConsumed {
+		prompts.reader, prompts.tty = bufio.NewReader(os.Stdin), false
+		return prompts.reader, nil
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, fmt.Errorf("stdin is being read for input and there is no terminal to prompt on, use --no-prompt to skip prompts")
+	}
+	prompts.reader, prompts.tty = bufio.NewReader(tty), true
+	return prompts.reader, nil
+}
+
+// indentedYaml returns v as yaml with each line indented by indent.
+func indentedYaml(v interface{}, indent string) (string, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		out.WriteString(indent + line + "\n")
+	}
+	return out.String(), nil
+}
+
+// ReadIdentifiers reads identifiers, one per line, from a file or from stdin if path is -. Blank lines and lines
+// starting with # are skipped.
+func ReadIdentifiers(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path == "-" {
+		stdinConsumed = true
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	return ids, scanner.Err()
+}
+
+// Prompt asks a question and returns the answer without surrounding whitespace.
+func Prompt(s string) (string, error) {
+	r, err := promptReader()
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("%s: ", s)
 	res, err := r.ReadString('\n')
 	if err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+	return strings.TrimSpace(res), nil
+}
+
+// Confirm asks a yes or no question, answers that don't start with y, including empty ones, are no. It returns an error
+// when there is no answer to read, eg. stdin was closed.
+func Confirm(s string) (bool, error) {
+	r, err := promptReader()
+	if err != nil {
+		return false, err
 	}
-	// Empty input (i.e. "\n")
-	if len(res) < 2 {
+	fmt.Printf("%s [y/n]: ", s)
+	res, err := r.ReadString('\n')
+	res = strings.ToLower(strings.TrimSpace(res))
+	// the last line of piped input may not end with a newline
+	if err != nil && (err != io.EOF || res == "") {
+		return false, fmt.Errorf("reading an answer to %q: %w", s, err)
+	}
+	return strings.HasPrefix(res, "y"), nil
+}
+
+// confirmed asks s with Confirm, an answer that can't be read is reported and treated as no.
+func confirmed(s string) bool {
+	ok, err := Confirm(s)
+	if err != nil {
+		ReportError(err)
 		return false
 	}
-	return strings.ToLower(strings.TrimSpace(res))[0] == 'y'
+	return ok
+}
+
+// choose asks the user to pick one of options and returns its index.
+func choose(s string, options []string) (int, error) {
+	fmt.Println(s + ":")
+	for i, o := range options {
+		fmt.Printf("  %d) %s\n", i+1, o)
+	}
+	r, err := promptReader()
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("Enter a number [1-%d]: ", len(options))
+	res, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(res))
+	if err != nil || choice < 1 || choice > len(options) {
+		return 0, fmt.Errorf("%q is not a valid choice", strings.TrimSpace(res))
+	}
+	return choice - 1, nil
 }
 
 func GetTableHeaders(resources []types.ResourceDescription) []interface{} {
@@ -32,7 +149,7 @@ func GetTableHeaders(resources []types.ResourceDescription) []interface{} {
 		var props map[string]interface{}
 		err := json.Unmarshal([]byte(*r.Properties), &props)
 		if err != nil {
-			fmt.Printf("failed to unmarshal json properties: %q %q\n", err.Error(), *r.Properties)
+			ReportError(fmt.Errorf("parsing the properties of %s: %w", *r.Identifier, err))
 		}
 		for k, _ := range props {
 			lk := strings.ToLower(k)
@@ -60,10 +177,8 @@ func createRows(tbl table.Table, resources []types.ResourceDescription, headers
 
 func GetRow(r types.ResourceDescription, headers []interface{}) []interface{} {
 	var props map[string]interface{}
-	err := json.Unmarshal([]byte(*r.Properties), &props)
-	if err != nil {
-		fmt.Printf("failed to unmarshal json properties: %q %q\n", err.Error(), *r.Properties)
-	}
+	// properties that can't be parsed were reported with the headers, the row only has the identifier
+	_ = json.Unmarshal([]byte(*r.Properties), &props)
 	row := []interface{}{*r.Identifier}
 	for _, header := range headers {
 		switch props[header.(string)].(type) {
@@ -0,0 +1,294 @@
+package data
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	bundleManifestName  = "manifest.json"
+	bundleFormatVersion = 1
+)
+
+// BundleManifest describes the schema files in a bundle. Bundles are gzipped tarballs containing the manifest and one
+// json file per schema, they allow the cache to be populated on machines that cannot reach the registry.
+type BundleManifest struct {
+	Version int           `json:"version"`
+	Created time.Time     `json:"created"`
+	Schemas []BundleEntry `json:"schemas"`
+}
+
+type BundleEntry struct {
+	TypeName string `json:"typeName"`
+	File     string `json:"file"`
+	Sha256   string `json:"sha256"`
+	SchemaVersion
+}
+
+// ExportSchemas writes the schema json files from the schema directory to a bundle at path. When typeNames are
+// supplied only those types are exported. Returns the number of exported schemas.
+func ExportSchemas(path string, typeNames []string) (int, error) {
+	schemaPath, err := absPath(schemaDir)
+	if err != nil {
+		return 0, err
+	}
+	versions := map[string]SchemaVersion{}
+	if c, err := NewCache(CacheROMode); err == nil {
+		versions, _ = c.GetVersions()
+		_ = c.cache.Close()
+	}
+	files := map[string][]byte{}
+	manifest := BundleManifest{Version: bundleFormatVersion, Created: time.Now().UTC()}
+	err = filepath.Walk(*schemaPath, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		schemaBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		schema, err := ParseSchema(schemaBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: skipping %q, it can't be parsed: %s\n", path, err.Error())
+			return nil
+		}
+		if len(typeNames) > 0 && !Contains(typeNames, schema.TypeName) {
+			return nil
+		}
+		name := filepath.Base(path)
+		files[name] = schemaBytes
+		manifest.Schemas = append(manifest.Schemas, BundleEntry{
+			TypeName:      schema.TypeName,
+			File:          name,
+			Sha256:        checksum(schemaBytes),
+			SchemaVersion: versions[schema.TypeName],
+		})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(manifest.Schemas) == 0 {
+		return 0, errors.New("no schemas found to export, run \"cloudctl upgrade\" first")
+	}
+	sort.Slice(manifest.Schemas, func(i, j int) bool {
+		return manifest.Schemas[i].TypeName < manifest.Schemas[j].TypeName
+	})
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = writeTarFile(tw, bundleManifestName, manifestBytes)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range manifest.Schemas {
+		err = writeTarFile(tw, entry.File, files[entry.File])
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return 0, err
+	}
+	err = gz.Close()
+	if err != nil {
+		return 0, err
+	}
+	return len(manifest.Schemas), nil
+}
+
+// ImportSchemas adds the schemas from a bundle, or from a directory of schema json files, to the schema directory and
+// the cache. Checksums are verified against the manifest when one is present, directories do not require a manifest
+// so that hand-written schemas can be imported. Returns the number of imported schemas.
+func ImportSchemas(path string) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	var files map[string][]byte
+	if info.IsDir() {
+		files, err = readSchemaDir(path)
+	} else {
+		files, err = readBundle(path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return importSchemaFiles(files)
+}
+
+func importSchemaFiles(files map[string][]byte) (int, error) {
+	var manifest *BundleManifest
+	if manifestBytes, ok := files[bundleManifestName]; ok {
+		manifest = &BundleManifest{}
+		err := json.Unmarshal(manifestBytes, manifest)
+		if err != nil {
+			return 0, fmt.Errorf("parsing bundle manifest: %s", err)
+		}
+		if manifest.Version > bundleFormatVersion {
+			return 0, fmt.Errorf("bundle format version %d is newer than this version of cloudctl supports", manifest.Version)
+		}
+		delete(files, bundleManifestName)
+	}
+	schemas := map[string]CfnSchema{}
+	versions := map[string]SchemaVersion{}
+	raw := map[string][]byte{}
+	if manifest != nil {
+		for _, entry := range manifest.Schemas {
+			schemaBytes, ok := files[entry.File]
+			if !ok {
+				return 0, fmt.Errorf("bundle is missing %q for %s", entry.File, entry.TypeName)
+			}
+			if checksum(schemaBytes) != entry.Sha256 {
+				return 0, fmt.Errorf("checksum mismatch for %q, the bundle may be corrupt", entry.File)
+			}
+			schema, err := parseImportedSchema(schemaBytes)
+			if err != nil {
+				return 0, fmt.Errorf("parsing %q: %s", entry.File, err)
+			}
+			schema.ProvisioningType = entry.ProvisioningType
+			schemas[schema.TypeName] = *schema
+			raw[schema.TypeName] = schemaBytes
+			versions[schema.TypeName] = entry.SchemaVersion
+		}
+	} else {
+		for name, schemaBytes := range files {
+			schema, err := parseImportedSchema(schemaBytes)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: skipping %q, it can't be parsed: %s\n", name, err.Error())
+				continue
+			}
+			schemas[schema.TypeName] = *schema
+			raw[schema.TypeName] = schemaBytes
+			versions[schema.TypeName] = SchemaVersion{Imported: true}
+		}
+	}
+	if len(schemas) == 0 {
+		return 0, errors.New("no schemas found to import")
+	}
+	err := withStagedCache(func(c *Cache) error {
+		for name, schemaBytes := range raw {
+			c.stageSchemaFile(name, schemaBytes)
+		}
+		return c.UpdateSchemas(schemas, versions, nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(schemas), nil
+}
+
+func parseImportedSchema(schemaBytes []byte) (*CfnSchema, error) {
+	schema, err := ParseSchema(schemaBytes)
+	if err != nil {
+		return nil, err
+	}
+	// type names become file names, so they are validated before anything is written
+	_, _, _, err = splitName(schema.TypeName)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(schema.TypeName, `/\.`) {
+		return nil, fmt.Errorf("invalid type name %q", schema.TypeName)
+	}
+	return schema, nil
+}
+
+func readSchemaDir(path string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = b
+	}
+	return files, nil
+}
+
+func readBundle(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle: %s", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle: %s", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle: %s", err)
+		}
+		files[filepath.Base(header.Name)] = b
+	}
+	if _, ok := files[bundleManifestName]; !ok {
+		return nil, errors.New("bundle does not contain a manifest")
+	}
+	return files, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, content []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(content)
+	return err
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -17,111 +19,296 @@ const (
 	// TODO: cross platform paths
 	cacheDir          = "~/.cloudctl/cache/"
 	cacheFilename     = "bbolt.db"
+	lockFilename      = "upgrade.lock"
+	stagingSuffix     = ".staging"
 	CacheROMode       = "RO"
 	CacheRWMode       = "RW"
 	defaultBucketName = "cloudctlDefault"
+	schemaListKey     = "__schemas__"
+	versionsKey       = "__versions__"
+	cacheVersionKey   = "__cache_version__"
+	// cacheVersion should be incremented whenever the layout of cached values changes, caches written with a
+	// different version are fully refreshed on the next upgrade.
+	cacheVersion = "4"
 )
 
+// SchemaVersion records the registry version a cached schema was downloaded at.
+type SchemaVersion struct {
+	DefaultVersionId string `json:"defaultVersionId"`
+	// VersionId pins a type to a non-default version, pinned types are skipped when upgrading from the registry
+	// listing and are only changed by upgrading the type explicitly.
+	VersionId        string    `json:"versionId,omitempty"`
+	ProvisioningType string    `json:"provisioningType"`
+	LastUpdated      time.Time `json:"lastUpdated"`
+	// Imported is set for schemas imported without registry version information, they are treated like pinned types
+	Imported bool `json:"imported,omitempty"`
+}
+
+func (v SchemaVersion) isStale(current aws.TypeVersion) bool {
+	return v.DefaultVersionId != current.DefaultVersionId ||
+		v.ProvisioningType != current.ProvisioningType ||
+		!v.LastUpdated.Equal(current.LastUpdated)
+}
+
+func (v SchemaVersion) isPinned() bool {
+	return v.VersionId != "" || v.Imported
+}
+
 type Cache struct {
 	Mode  string
+	path  string
 	cache *bolt.DB
+	// schemaFiles are the schema json files to write once a staged cache has been swapped in, nil contents remove the
+	// type's file
+	schemaFiles map[string][]byte
 }
 
 func NewCache(mode string) (*Cache, error) {
 	cachePath, err := absPath(cacheDir)
 	if err != nil {
-		fmt.Printf("ERROR: finding cache dir: %q\n", err.Error())
-		return nil, err
+		return nil, fmt.Errorf("finding cache dir: %w", err)
 	}
 	if mode == CacheRWMode {
 		err = mkCacheDir(cachePath)
 		if err != nil {
-			fmt.Printf("ERROR: creating cache dir: %q\n", err.Error())
-			return nil, err
+			return nil, fmt.Errorf("creating cache dir: %w", err)
 		}
 	}
-	db, err := bolt.Open(*cachePath+cacheFilename, 0666, &bolt.Options{ReadOnly: isRO(mode), Timeout: 1 * time.Second})
+	return openCache(*cachePath+cacheFilename, mode)
+}
+
+func openCache(path string, mode string) (*Cache, error) {
+	db, err := bolt.Open(path, 0666, &bolt.Options{ReadOnly: isRO(mode), Timeout: 1 * time.Second})
 	if err != nil {
-		fmt.Printf("ERROR: opening bolt cache: %q\n", err.Error())
-		return nil, err
+		return nil, fmt.Errorf("opening bolt cache: %w", err)
 	}
 	c := Cache{
 		Mode:  mode,
+		path:  path,
 		cache: db,
 	}
 	if mode == CacheRWMode {
 		err = c.createBucket()
 		if err != nil {
-			fmt.Printf("ERROR: creating bolt cache bucket: %q\n", err.Error())
-			return nil, err
+			return nil, fmt.Errorf("creating bolt cache bucket: %w", err)
 		}
 	}
 	return &c, nil
 }
 
-func UpdateCache() error {
-	// TODO: create lock to prevent concurrent upgrade operations from racing
-	fmt.Println("Downloading schema files...")
-	c, err := NewCache(CacheRWMode)
-	if err != nil {
-		fmt.Printf("ERROR: opening cache: %q\n", err.Error())
-		return err
+// lockCache takes an exclusive lock that is held for the duration of an upgrade. bbolt already implements cross
+// platform file locking, so the lock is a bolt db that is never written to. The lock is released when the returned db
+// is closed or the process exits.
+func lockCache(cachePath string) (*bolt.DB, error) {
+	lock, err := bolt.Open(cachePath+lockFilename, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err == bolt.ErrTimeout {
+		return nil, errors.New("another cloudctl upgrade is already running, try again once it has completed")
+	}
+	return lock, err
+}
+
+// stageCache creates a writable copy of the live cache. Changes are made to the copy and then swapped in with
+// swapCache, so readers only ever see a complete cache.
+func stageCache(cachePath string) (*Cache, error) {
+	stagingPath := cachePath + cacheFilename + stagingSuffix
+	err := os.Remove(stagingPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
 	}
-	defer func(cache *bolt.DB) {
-		err := cache.Close()
+	if _, err := os.Stat(cachePath + cacheFilename); err == nil {
+		live, err := openCache(cachePath+cacheFilename, CacheROMode)
 		if err != nil {
-			fmt.Printf("ERROR: closing cache error: %s\n", err.Error())
+			return nil, err
+		}
+		err = live.cache.View(func(tx *bolt.Tx) error {
+			return tx.CopyFile(stagingPath, 0666)
+		})
+		closeErr := live.cache.Close()
+		if err != nil {
+			return nil, fmt.Errorf("copying cache to staging: %s", err)
 		}
-	}(c.cache)
+		if closeErr != nil {
+			return nil, closeErr
+		}
+	}
+	return openCache(stagingPath, CacheRWMode)
+}
 
-	schemas, err := aws.FetchSchemas()
+// swapCache closes a staged cache and atomically replaces the live cache with it. Processes that already have the
+// live cache open continue to read the previous version.
+func (c Cache) swapCache(cachePath string) error {
+	err := c.cache.Close()
 	if err != nil {
-		fmt.Printf("ERROR: fetching schemas: %q\n", err.Error())
 		return err
 	}
-	fmt.Println("Saving schema json files to disk...")
-	schemaPath, err := absPath(schemaDir)
+	// TODO: rename fails on windows if a reader has the live cache open
+	return os.Rename(c.path, cachePath+cacheFilename)
+}
+
+// UpdateCache refreshes the schema cache. When typeNames are supplied only those types are downloaded, at versionId if
+// it is set, otherwise the registry is listed and only types whose version changed since the last upgrade are
+// downloaded. full ignores the versions recorded in the cache and downloads every type that isn't pinned to a version.
+// The cache is only modified once all downloads have completed, and then in a single transaction, so an interrupted
+// upgrade leaves the previous cache intact.
+func UpdateCache(ctx context.Context, typeNames []string, versionId string, full bool) error {
+	if versionId != "" && len(typeNames) != 1 {
+		return errors.New("a version can only be requested for a single type")
+	}
+	return withStagedCache(func(c *Cache) error {
+		return updateSchemas(ctx, c, typeNames, versionId, full)
+	})
+}
+
+// withStagedCache holds the upgrade lock while update modifies a staged copy of the cache. The staged copy replaces
+// the live cache if update succeeds and is discarded otherwise.
+func withStagedCache(update func(c *Cache) error) error {
+	cachePath, err := absPath(cacheDir)
 	if err != nil {
 		return err
 	}
-	err = mkCacheDir(schemaPath)
+	err = mkCacheDir(cachePath)
 	if err != nil {
 		return err
 	}
-	var schemaList []string
-	for name, schema := range *schemas {
-		schemaList = append(schemaList, name)
-		fname := *schemaPath + strings.Replace(name, "::", "_", -1) + ".json"
-		err = ioutil.WriteFile(fname, schema, 0644)
-		if err != nil {
-			return err
-		}
+	lock, err := lockCache(*cachePath)
+	if err != nil {
+		return fmt.Errorf("locking cache: %w", err)
 	}
-	fmt.Println("Parsing schema files...")
-	parsedSchemas, err := ParseSchemas()
+	// the lock is released when the process exits even if closing it fails
+	defer func(lock *bolt.DB) { _ = lock.Close() }(lock)
+	c, err := stageCache(*cachePath)
 	if err != nil {
-		return err
+		return fmt.Errorf("opening cache: %w", err)
 	}
-	fmt.Println("Resetting cache...")
-	err = c.deleteBucket()
+	err = update(c)
 	if err != nil {
+		_ = c.cache.Close()
+		_ = os.Remove(c.path)
 		return err
 	}
-	err = c.createBucket()
+	err = c.swapCache(*cachePath)
 	if err != nil {
 		return err
 	}
-	err = c.PutSchemas(*parsedSchemas)
+	// the files follow the committed cache, so a failed upgrade leaves both as they were
+	return writeSchemaFiles(c.schemaFiles)
+}
+
+// stageSchemaFile records a schema file to write, or remove if schema is nil, once the staged cache is swapped in.
+func (c *Cache) stageSchemaFile(typeName string, schema []byte) {
+	if c.schemaFiles == nil {
+		c.schemaFiles = map[string][]byte{}
+	}
+	c.schemaFiles[typeName] = schema
+}
+
+func writeSchemaFiles(files map[string][]byte) error {
+	if len(files) == 0 {
+		return nil
+	}
+	fmt.Println("Saving schema json files to disk...")
+	schemaPath, err := absPath(schemaDir)
 	if err != nil {
 		return err
 	}
-	err = c.PutLists(map[string][]string{"__schemas__": schemaList})
+	err = mkCacheDir(schemaPath)
 	if err != nil {
 		return err
 	}
+	for name, schema := range files {
+		if schema == nil {
+			err = os.Remove(schemaFileName(*schemaPath, name))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		err = ioutil.WriteFile(schemaFileName(*schemaPath, name), schema, 0644)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func updateSchemas(ctx context.Context, c *Cache, typeNames []string, versionId string, full bool) error {
+	versions, err := c.GetVersions()
+	if err != nil {
+		return err
+	}
+	var removed []string
+	schemas := map[string]aws.TypeSchema{}
+	if len(typeNames) > 0 {
+		fmt.Println("Downloading schema files...")
+		for _, name := range typeNames {
+			schema, err := aws.FetchSchema(ctx, name, versionId)
+			if err != nil {
+				return fmt.Errorf("fetching schema for %q: %w", name, err)
+			}
+			schemas[schema.TypeName] = *schema
+		}
+	} else {
+		fmt.Println("Listing registry types...")
+		current, err := aws.ListTypes(ctx)
+		if err != nil {
+			return fmt.Errorf("listing types: %w", err)
+		}
+		var changed []aws.TypeVersion
+		for name, v := range *current {
+			cached, ok := versions[name]
+			if ok && cached.isPinned() {
+				continue
+			}
+			if full || !ok || cached.isStale(v) {
+				changed = append(changed, v)
+			}
+		}
+		cached, err := c.GetList(schemaListKey)
+		if err == nil {
+			for _, name := range *cached {
+				if _, ok := (*current)[name]; !ok && !versions[name].isPinned() {
+					removed = append(removed, name)
+				}
+			}
+		}
+		fmt.Printf("%d of %d types changed since the last upgrade, %d removed\n", len(changed), len(*current), len(removed))
+		if len(changed) == 0 && len(removed) == 0 {
+			return nil
+		}
+		fmt.Println("Downloading schema files...")
+		fetched, err := aws.FetchSchemas(ctx, changed)
+		if err != nil {
+			return fmt.Errorf("fetching schemas: %w", err)
+		}
+		schemas = *fetched
+	}
+	for _, name := range removed {
+		c.stageSchemaFile(name, nil)
+	}
+	fmt.Println("Parsing schema files...")
+	parsedSchemas := map[string]CfnSchema{}
+	newVersions := map[string]SchemaVersion{}
+	for name, schema := range schemas {
+		parsed, err := ParseSchema(schema.Schema)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: skipping %q, it can't be parsed: %s\n", name, err.Error())
+			continue
+		}
+		// only schemas that parse are saved, a broken file would replace a working one
+		c.stageSchemaFile(name, schema.Schema)
+		parsed.ProvisioningType = schema.ProvisioningType
+		parsedSchemas[name] = *parsed
+		newVersions[name] = SchemaVersion{
+			DefaultVersionId: schema.DefaultVersionId,
+			VersionId:        schema.VersionId,
+			ProvisioningType: schema.ProvisioningType,
+			LastUpdated:      schema.LastUpdated,
+		}
+	}
+	fmt.Println("Updating cache...")
+	return c.UpdateSchemas(parsedSchemas, newVersions, removed)
+}
+
 func (c Cache) createBucket() error {
 	err := c.cache.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(defaultBucketName))
@@ -231,6 +418,107 @@ func (c Cache) PutSchemas(cacheMap map[string]CfnSchema) error {
 	return err
 }
 
+// GetVersions returns the registry versions of the cached schemas. Caches written by a different cacheVersion return
+// no versions so that every type is downloaded again.
+func (c Cache) GetVersions() (map[string]SchemaVersion, error) {
+	versions := map[string]SchemaVersion{}
+	v, err := c.Get(cacheVersionKey)
+	if err != nil || *v != cacheVersion {
+		return versions, nil
+	}
+	value, err := c.Get(versionsKey)
+	if err != nil {
+		return versions, nil
+	}
+	err = json.Unmarshal([]byte(*value), &versions)
+	return versions, err
+}
+
+// UpdateSchemas writes schemas and their versions to the cache and deletes the removed types in a single transaction,
+// keeping the schema list and versions consistent with the cached schemas.
+func (c Cache) UpdateSchemas(schemas map[string]CfnSchema, versions map[string]SchemaVersion, removed []string) error {
+	err := c.cache.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(defaultBucketName))
+		var schemaList []string
+		if v := b.Get([]byte(schemaListKey)); v != nil {
+			err := json.Unmarshal(v, &schemaList)
+			if err != nil {
+				return err
+			}
+		}
+		cachedVersions := map[string]SchemaVersion{}
+		if v := b.Get([]byte(cacheVersionKey)); v != nil && string(v) == cacheVersion {
+			if v := b.Get([]byte(versionsKey)); v != nil {
+				err := json.Unmarshal(v, &cachedVersions)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for _, name := range removed {
+			err := b.Delete([]byte(name))
+			if err != nil {
+				return err
+			}
+			delete(cachedVersions, name)
+		}
+		var newList []string
+		for _, name := range schemaList {
+			if !Contains(removed, name) {
+				newList = append(newList, name)
+			}
+		}
+		for name, schema := range schemas {
+			jsonV, err := schema.ToJsonString()
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(name), []byte(*jsonV))
+			if err != nil {
+				return err
+			}
+			if !Contains(newList, name) {
+				newList = append(newList, name)
+			}
+		}
+		for name, v := range versions {
+			cachedVersions[name] = v
+		}
+		sort.Strings(newList)
+		err := updateIndex(b, newList, schemas, removed)
+		if err != nil {
+			return err
+		}
+		listV, err := json.Marshal(newList)
+		if err != nil {
+			return err
+		}
+		versionsV, err := json.Marshal(cachedVersions)
+		if err != nil {
+			return err
+		}
+		for k, v := range map[string][]byte{
+			schemaListKey:   listV,
+			versionsKey:     versionsV,
+			cacheVersionKey: []byte(cacheVersion),
+		} {
+			err = b.Put([]byte(k), v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("writing to cache: %s", err)
+	}
+	return nil
+}
+
+func schemaFileName(schemaPath string, typeName string) string {
+	return schemaPath + strings.Replace(typeName, "::", "_", -1) + ".json"
+}
+
 func absPath(path string) (*string, error) {
 	if strings.HasPrefix(path, "~") {
 		homeDir, err := os.UserHomeDir()
@@ -258,8 +546,7 @@ func mkCacheDir(cachePath *string) error {
 	if _, err := os.Stat(*cachePath); os.IsNotExist(err) {
 		err := os.MkdirAll(*cachePath, 0755)
 		if err != nil {
-			fmt.Printf("ERROR: creating cache directory: %q\n", err.Error())
-			return err
+			return fmt.Errorf("creating cache directory: %w", err)
 		}
 	}
 	return nil
@@ -270,7 +557,7 @@ func GetSchemas() (*map[string]map[string]map[string]CfnSchema, error) {
 	if err != nil {
 		return nil, err
 	}
-	schemaList, err := c.GetList("__schemas__")
+	schemaList, err := c.GetList(schemaListKey)
 	if err != nil {
 		return nil, err
 	}
@@ -278,7 +565,7 @@ func GetSchemas() (*map[string]map[string]map[string]CfnSchema, error) {
 	for _, name := range *schemaList {
 		schema, err := c.GetSchema(name)
 		if err != nil {
-			fmt.Printf("ERROR: failed to get schema for %q: %q\n", name, err.Error())
+			return nil, fmt.Errorf("getting schema for %q: %w", name, err)
 		}
 		provider, service, resource, err := splitName(name)
 		if err != nil {
@@ -302,11 +589,8 @@ func GetSchema(typeName string) (*CfnSchema, error) {
 	if err != nil {
 		return nil, err
 	}
-	schema, err := c.GetSchema(typeName)
-	if err != nil {
-		fmt.Printf("ERROR: failed to get schema for %q: %q\n", typeName, err.Error())
-	}
-	return schema, err
+	defer c.cache.Close()
+	return c.GetSchema(typeName)
 }
 
 func splitName(name string) (*string, *string, *string, error) {
@@ -330,7 +614,7 @@ func keyExists(inputMap interface{}, key string) bool {
 	case map[string]map[string]CfnSchema:
 		m2 = inputMap.(map[string]map[string]CfnSchema)
 	default:
-		fmt.Printf("ERROR cannot check keys for %q %q\n", key, g)
+		fmt.Fprintf(os.Stderr, "ERROR cannot check keys for %q %q\n", key, g)
 	}
 	if m1 != nil {
 		for k, _ := range m1 {
@@ -395,3 +679,12 @@ func checkSchema(input interface{}, checkType string) bool {
 	}
 	return false
 }
+
+// CredentialsPath returns the directory that assumed role credentials are cached in between commands.
+func CredentialsPath() (string, error) {
+	cachePath, err := absPath(cacheDir)
+	if err != nil {
+		return "", err
+	}
+	return *cachePath + "credentials/", nil
+}
@@ -0,0 +1,194 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	indexKey = "__index__"
+)
+
+// IndexEntry is the subset of a schema needed to build the command tree.
+type IndexEntry struct {
+	TypeName         string `json:"typeName"`
+	Description      string `json:"description,omitempty"`
+	DocumentationUrl string `json:"documentationUrl,omitempty"`
+	Updatable        bool   `json:"updatable,omitempty"`
+	Configurable     bool   `json:"configurable,omitempty"`
+	// References maps the paths of properties that refer to other resources to the type they refer to, or to the name
+	// the type is inferred from, see ReferencedType
+	References map[string]string `json:"references,omitempty"`
+}
+
+// Index maps provider, service and resource names to index entries. It is kept in the cache alongside the schemas so
+// that commands can be built without deserialising every schema.
+type Index map[string]map[string]map[string]IndexEntry
+
+func NewIndexEntry(schema CfnSchema) IndexEntry {
+	return IndexEntry{
+		TypeName:         schema.TypeName,
+		Description:      schema.Description,
+		DocumentationUrl: schema.DocumentationUrl,
+		Updatable:        schema.IsUpdatable(),
+		Configurable:     schema.IsConfigurable(),
+		References:       schema.referenceProperties(),
+	}
+}
+
+func (i Index) Add(schema CfnSchema) error {
+	provider, service, resource, err := splitName(schema.TypeName)
+	if err != nil {
+		return err
+	}
+	if _, ok := i[*provider]; !ok {
+		i[*provider] = map[string]map[string]IndexEntry{}
+	}
+	if _, ok := i[*provider][*service]; !ok {
+		i[*provider][*service] = map[string]IndexEntry{}
+	}
+	i[*provider][*service][*resource] = NewIndexEntry(schema)
+	return nil
+}
+
+func (i Index) Remove(typeName string) {
+	provider, service, resource, err := splitName(typeName)
+	if err != nil {
+		return
+	}
+	delete(i[*provider][*service], *resource)
+	if len(i[*provider][*service]) == 0 {
+		delete(i[*provider], *service)
+	}
+	if len(i[*provider]) == 0 {
+		delete(i, *provider)
+	}
+}
+
+// Len returns the number of resource types in the index.
+func (i Index) Len() int {
+	count := 0
+	for _, services := range i {
+		for _, resources := range services {
+			count += len(resources)
+		}
+	}
+	return count
+}
+
+func (i Index) Updatable(provider string, service string) bool {
+	for s, resources := range i[provider] {
+		if service != "" && s != service {
+			continue
+		}
+		for _, entry := range resources {
+			if entry.Updatable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (i Index) Configurable(provider string, service string) bool {
+	for s, resources := range i[provider] {
+		if service != "" && s != service {
+			continue
+		}
+		for _, entry := range resources {
+			if entry.Configurable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c Cache) GetIndex() (*Index, error) {
+	index := Index{}
+	value, err := c.Get(indexKey)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(*value), &index)
+	return &index, err
+}
+
+// GetIndex returns the schema index. Caches written before the index was introduced, or by a different cacheVersion,
+// are indexed from their schemas until the next upgrade writes an index.
+func GetIndex() (*Index, error) {
+	c, err := NewCache(CacheROMode)
+	if err != nil {
+		return nil, err
+	}
+	defer c.cache.Close()
+	if _, err := c.Get(indexKey); err != nil {
+		return c.buildIndex()
+	}
+	if v, err := c.Get(cacheVersionKey); err != nil || *v != cacheVersion {
+		return c.buildIndex()
+	}
+	return c.GetIndex()
+}
+
+func (c Cache) buildIndex() (*Index, error) {
+	index := Index{}
+	schemaList, err := c.GetList(schemaListKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range *schemaList {
+		schema, err := c.GetSchema(name)
+		if err != nil {
+			return nil, fmt.Errorf("getting schema for %q: %w", name, err)
+		}
+		err = index.Add(*schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &index, nil
+}
+
+// updateIndex applies schema changes to the index inside a cache write transaction. If the cache does not have an index
+// yet, or it was written by a different cacheVersion, it is built from every cached schema.
+func updateIndex(b *bolt.Bucket, schemaList []string, schemas map[string]CfnSchema, removed []string) error {
+	index := Index{}
+	if v := b.Get([]byte(indexKey)); v != nil && string(b.Get([]byte(cacheVersionKey))) == cacheVersion {
+		err := json.Unmarshal(v, &index)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, name := range schemaList {
+			v := b.Get([]byte(name))
+			if v == nil {
+				continue
+			}
+			var schema CfnSchema
+			err := json.Unmarshal(v, &schema)
+			if err != nil {
+				return fmt.Errorf("indexing %q: %s", name, err)
+			}
+			err = index.Add(schema)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range removed {
+		index.Remove(name)
+	}
+	for _, schema := range schemas {
+		err := index.Add(schema)
+		if err != nil {
+			return err
+		}
+	}
+	indexV, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(indexKey), indexV)
+}
@@ -0,0 +1,96 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	typesCC "github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+const (
+	// lists are kept out of the schema cache, which is replaced wholesale by upgrades
+	listCacheFilename  = "lists.db"
+	listBucketName     = "lists"
+	accountsBucketName = "accounts"
+)
+
+type cachedList struct {
+	Fetched   time.Time                     `json:"fetched"`
+	Resources []typesCC.ResourceDescription `json:"resources"`
+}
+
+// listKey keys a list by the scope it was made in, eg. the account and region, as well as its type.
+func listKey(scope string, typeName string) []byte {
+	return []byte(scope + "|" + typeName)
+}
+
+// GetCachedList returns the resources of typeName if they were listed in scope within ttl. The list cache is best
+// effort, it is shared by concurrent shell completions so any failure to open it is treated as a cache miss.
+func GetCachedList(scope string, typeName string, ttl time.Duration) (*[]typesCC.ResourceDescription, bool) {
+	var list cachedList
+	err := withListCache(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(listBucketName))
+		v := b.Get(listKey(scope, typeName))
+		if v == nil {
+			return errors.New("not cached")
+		}
+		return json.Unmarshal(v, &list)
+	})
+	if err != nil || time.Since(list.Fetched) > ttl {
+		return nil, false
+	}
+	return &list.Resources, true
+}
+
+func PutCachedList(scope string, typeName string, resources []typesCC.ResourceDescription) error {
+	v, err := json.Marshal(cachedList{Fetched: time.Now(), Resources: resources})
+	if err != nil {
+		return err
+	}
+	return withListCache(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(listBucketName)).Put(listKey(scope, typeName), v)
+	})
+}
+
+// GetCachedAccount returns the account that the credentials with accessKeyId belong to, if it has been looked up.
+func GetCachedAccount(accessKeyId string) (string, bool) {
+	var account string
+	err := withListCache(func(tx *bolt.Tx) error {
+		account = string(tx.Bucket([]byte(accountsBucketName)).Get([]byte(accessKeyId)))
+		return nil
+	})
+	return account, err == nil && account != ""
+}
+
+// PutCachedAccount records the account of an access key, keys never move between accounts so it doesn't expire.
+func PutCachedAccount(accessKeyId string, account string) error {
+	return withListCache(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(accountsBucketName)).Put([]byte(accessKeyId), []byte(account))
+	})
+}
+
+func withListCache(fn func(tx *bolt.Tx) error) error {
+	cachePath, err := absPath(cacheDir)
+	if err != nil {
+		return err
+	}
+	err = mkCacheDir(cachePath)
+	if err != nil {
+		return err
+	}
+	db, err := bolt.Open(*cachePath+listCacheFilename, 0600, &bolt.Options{Timeout: 200 * time.Millisecond})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{listBucketName, accountsBucketName} {
+			_, err := tx.CreateBucketIfNotExists([]byte(name))
+			if err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
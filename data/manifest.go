@@ -0,0 +1,149 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Manifest is the desired state of a single resource, the unit that apply consumes. A manifest file is a stream of
+// yaml documents, one per resource.
+type Manifest struct {
+	TypeName string `yaml:"typeName" json:"typeName"`
+	// Name identifies the manifest to other manifests, properties refer to other manifests' resources with
+	// {Ref: Name} or {Fn::GetAtt: [Name, Attribute]}
+	Name string `yaml:"name" json:"name"`
+	// Identifier is the primary identifier of an existing resource that the manifest manages
+	Identifier string                 `yaml:"identifier,omitempty" json:"identifier,omitempty"`
+	DependsOn  []string               `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+	Properties map[string]interface{} `yaml:"properties,omitempty" json:"properties,omitempty"`
+	// Unresolved lists properties whose values could not be determined when the manifest was generated, they must be
+	// edited before the manifest can be applied
+	Unresolved []string `yaml:"unresolved,omitempty" json:"unresolved,omitempty"`
+}
+
+// ReadManifests reads manifests from a file, every .yaml, .yml and .json file in a directory, or stdin if path is -.
+func ReadManifests(path string) ([]Manifest, error) {
+	if path == "-" {
+		return decodeManifests(os.Stdin, "stdin")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if !e.IsDir() && (ext == ".yaml" || ext == ".yml" || ext == ".json") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+	var manifests []Manifest
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		m, err := decodeManifests(bytes.NewReader(b), file)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m...)
+	}
+	return manifests, nil
+}
+
+func decodeManifests(r io.Reader, source string) ([]Manifest, error) {
+	var manifests []Manifest
+	decoder := yaml.NewDecoder(r)
+	for {
+		var m Manifest
+		err := decoder.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			return manifests, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading manifests from %s: %s", source, err)
+		}
+		if m.TypeName == "" || m.Name == "" {
+			return nil, fmt.Errorf("reading manifests from %s: manifests require a typeName and a name", source)
+		}
+		manifests = append(manifests, m)
+	}
+}
+
+// WriteManifests writes manifests as a stream of yaml documents.
+func WriteManifests(w io.Writer, manifests []Manifest) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, m := range manifests {
+		if err := encoder.Encode(m); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+// IsIntrinsic reports whether v is a CloudFormation intrinsic function, eg. {Ref: MyVpc}.
+func IsIntrinsic(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return false
+	}
+	for k := range m {
+		return k == "Ref" || k == "Condition" || strings.HasPrefix(k, "Fn::")
+	}
+	return false
+}
+
+// UnknownProperties returns the paths of the properties in doc that are not in the schema. Objects without properties
+// in the schema, like policy documents, and intrinsic functions are not checked.
+func (s CfnSchema) UnknownProperties(doc map[string]interface{}) []string {
+	var unknown []string
+	s.unknownProperties("", doc, s.Properties, &unknown)
+	sort.Strings(unknown)
+	return unknown
+}
+
+func (s CfnSchema) unknownProperties(prefix string, doc map[string]interface{}, props map[string]interface{}, unknown *[]string) {
+	for name, v := range doc {
+		def, ok := props[name]
+		if !ok {
+			*unknown = append(*unknown, prefix+name)
+			continue
+		}
+		s.unknownValue(prefix+name, v, s.ResolveRef(def), unknown)
+	}
+}
+
+func (s CfnSchema) unknownValue(path string, v interface{}, def map[string]interface{}, unknown *[]string) {
+	if IsIntrinsic(v) || def == nil {
+		return
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if children, ok := def["properties"].(map[string]interface{}); ok {
+			s.unknownProperties(path+".", t, children, unknown)
+		}
+	case []interface{}:
+		items := s.ResolveRef(def["items"])
+		for _, item := range t {
+			s.unknownValue(path, item, items, unknown)
+		}
+	}
+}
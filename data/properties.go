@@ -0,0 +1,159 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxPropertyDepth limits how far nested object properties are expanded into paths
+	maxPropertyDepth = 4
+)
+
+// PropertyPath is a property addressed by a dot separated path, eg. VersioningConfiguration.Status.
+type PropertyPath struct {
+	Path        string
+	Type        string
+	Description string
+	Enum        []interface{}
+	ReadOnly    bool
+}
+
+// PropertyPaths returns the paths of the schema's properties, including the properties of nested objects. Array items
+// are not expanded, arrays are set as a whole.
+func (s CfnSchema) PropertyPaths() []PropertyPath {
+	var paths []PropertyPath
+	for name, iface := range s.Properties {
+		s.walkProperty(name, iface, 0, false, &paths)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].Path < paths[j].Path
+	})
+	return paths
+}
+
+func (s CfnSchema) walkProperty(path string, iface interface{}, depth int, parentReadOnly bool, paths *[]PropertyPath) {
+	propMap := s.ResolveRef(iface)
+	if propMap == nil {
+		return
+	}
+	p := PropertyPath{
+		Path:     path,
+		Type:     propertyType(propMap),
+		ReadOnly: parentReadOnly || Contains(s.ReadOnlyProperties, strings.Replace(path, ".", "/", -1)),
+	}
+	if desc, ok := propMap["description"].(string); ok {
+		p.Description = desc
+	}
+	if enum, ok := propMap["enum"].([]interface{}); ok {
+		p.Enum = enum
+	}
+	*paths = append(*paths, p)
+	if p.Type != "object" || depth >= maxPropertyDepth {
+		return
+	}
+	children, _ := propMap["properties"].(map[string]interface{})
+	for name, child := range children {
+		s.walkProperty(path+"."+name, child, depth+1, p.ReadOnly, paths)
+	}
+}
+
+// Property returns the definition of the property at path, with any $ref resolved.
+func (s CfnSchema) Property(path string) (map[string]interface{}, bool) {
+	parts := strings.Split(path, ".")
+	propMap := s.ResolveRef(s.Properties[parts[0]])
+	for _, part := range parts[1:] {
+		if propMap == nil {
+			return nil, false
+		}
+		children, _ := propMap["properties"].(map[string]interface{})
+		propMap = s.ResolveRef(children[part])
+	}
+	return propMap, propMap != nil
+}
+
+// SetProperty sets the property at path in doc, creating any parent objects. Values of string properties are used as
+// is, other values are parsed as yaml so that numbers, booleans, lists and objects can be supplied.
+func (s CfnSchema) SetProperty(doc map[string]interface{}, path string, value string) error {
+	propMap, ok := s.Property(path)
+	if !ok {
+		return fmt.Errorf("%s does not have a property %q", s.TypeName, path)
+	}
+	var parsed interface{} = value
+	if propertyType(propMap) != "string" {
+		err := yaml.Unmarshal([]byte(value), &parsed)
+		if err != nil {
+			return fmt.Errorf("parsing value for %q: %s", path, err)
+		}
+	}
+	// a single value for a list property, eg. SubnetIds=subnet-1234, is a list with one item
+	if _, ok := parsed.([]interface{}); !ok && propertyType(propMap) == "array" {
+		parsed = []interface{}{parsed}
+	}
+	parts := strings.Split(path, ".")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = parsed
+	return nil
+}
+
+// GetPath returns the value at a dot separated path in a resource's properties.
+func GetPath(doc map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// ParseAssignment splits a Path=value flag value.
+func ParseAssignment(assignment string) (string, string, error) {
+	parts := strings.SplitN(assignment, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", errors.New(fmt.Sprintf("%q should be in the format Path=value", assignment))
+	}
+	return parts[0], parts[1], nil
+}
+
+// ResolveRef returns a property definition, following its $ref to the schema's definitions if it has one.
+func (s CfnSchema) ResolveRef(iface interface{}) map[string]interface{} {
+	propMap, ok := iface.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if ref, ok := propMap["$ref"].(string); ok {
+		def, _ := s.Definitions[strings.Replace(ref, "#/definitions/", "", 1)].(map[string]interface{})
+		return def
+	}
+	return propMap
+}
+
+// ToString formats a scalar property value the way it would be written on the command line.
+func ToString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return fmt.Sprint(t)
+	}
+}
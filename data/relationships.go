@@ -0,0 +1,185 @@
+package data
+
+import (
+	"sort"
+	"strings"
+)
+
+// referenceSuffixes are property name suffixes that indicate a property refers to another resource, eg. VpcId or
+// SubnetIds.
+var referenceSuffixes = []string{"Ids", "Id", "Arns", "Arn"}
+
+// ReferencedType infers the resource type a property refers to from its name, eg. VpcId refers to AWS::EC2::VPC and
+// KmsKeyId to AWS::KMS::Key. Types in the same service as typeName are preferred, a match in another service is only
+// used when it is unambiguous.
+func (i Index) ReferencedType(typeName string, propName string) (string, bool) {
+	noun := referenceNoun(propName)
+	if noun == "" {
+		return "", false
+	}
+	provider, service, _, err := splitName(typeName)
+	if err != nil {
+		return "", false
+	}
+	var matches []string
+	for s, resources := range i[*provider] {
+		ls := strings.ToLower(s)
+		for r, entry := range resources {
+			lr := strings.ToLower(r)
+			if noun != lr && noun != ls+lr {
+				continue
+			}
+			if s == *service {
+				return entry.TypeName, true
+			}
+			matches = append(matches, entry.TypeName)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], true
+	}
+	return "", false
+}
+
+// Reference is a property of TypeName, addressed by Path, that refers to a resource of type Target.
+type Reference struct {
+	TypeName string
+	Path     string
+	Target   string
+}
+
+// referenceNoun returns the lower cased name of the resource a property refers to, eg. vpc for VpcId, or an empty
+// string if the property does not look like a reference.
+func referenceNoun(propName string) string {
+	for _, suffix := range referenceSuffixes {
+		if strings.HasSuffix(propName, suffix) && len(propName) > len(suffix) {
+			return strings.ToLower(strings.TrimSuffix(propName, suffix))
+		}
+	}
+	return ""
+}
+
+// referenceProperties returns the settable properties that refer to other resources, mapped to the type they refer
+// to when the schema declares it with a relationshipRef, or otherwise to the property or $ref definition name the type
+// can be inferred from. The resource's own identifiers are excluded.
+func (s CfnSchema) referenceProperties() map[string]string {
+	refs := map[string]string{}
+	for _, p := range s.PropertyPaths() {
+		if p.ReadOnly || Contains(s.PrimaryIdentifierNames(), p.Path) {
+			continue
+		}
+		if hint := s.referenceHint(p.Path); hint != "" {
+			refs[p.Path] = hint
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	return refs
+}
+
+// referenceHint returns the relationshipRef type name, or a property or definition name that looks like a reference,
+// for the property at path. Array properties are checked by their items.
+func (s CfnSchema) referenceHint(path string) string {
+	parts := strings.Split(path, ".")
+	var raw map[string]interface{}
+	if len(parts) == 1 {
+		raw, _ = s.Properties[path].(map[string]interface{})
+	} else if parent, ok := s.Property(strings.Join(parts[:len(parts)-1], ".")); ok {
+		children, _ := parent["properties"].(map[string]interface{})
+		raw, _ = children[parts[len(parts)-1]].(map[string]interface{})
+	}
+	if items, ok := raw["items"].(map[string]interface{}); ok {
+		raw = items
+	}
+	if rel, ok := raw["relationshipRef"].(map[string]interface{}); ok {
+		if typeName, ok := rel["typeName"].(string); ok {
+			return typeName
+		}
+	}
+	if name := parts[len(parts)-1]; referenceNoun(name) != "" {
+		return name
+	}
+	if ref, ok := raw["$ref"].(string); ok {
+		if name := strings.Replace(ref, "#/definitions/", "", 1); referenceNoun(name) != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// Entry returns the index entry for typeName.
+func (i Index) Entry(typeName string) (IndexEntry, bool) {
+	provider, service, resource, err := splitName(typeName)
+	if err != nil {
+		return IndexEntry{}, false
+	}
+	entry, ok := i[*provider][*service][*resource]
+	return entry, ok
+}
+
+// References returns typeName's references to other indexed types.
+func (i Index) References(typeName string) []Reference {
+	entry, ok := i.Entry(typeName)
+	if !ok {
+		return nil
+	}
+	var refs []Reference
+	for path, hint := range entry.References {
+		if strings.Contains(hint, "::") {
+			refs = append(refs, Reference{TypeName: typeName, Path: path, Target: hint})
+		} else if target, ok := i.ReferencedType(typeName, hint); ok {
+			refs = append(refs, Reference{TypeName: typeName, Path: path, Target: target})
+		}
+	}
+	sort.Slice(refs, func(a, b int) bool {
+		return refs[a].Path < refs[b].Path
+	})
+	return refs
+}
+
+// Dependents maps each indexed type to the references to it from every indexed type, eg. AWS::EC2::Subnet's VpcId is
+// a dependent of AWS::EC2::VPC. Walking dependents looks them up for every resource, so the map is built once rather
+// than scanning the index for each.
+func (i Index) Dependents() map[string][]Reference {
+	dependents := map[string][]Reference{}
+	for _, services := range i {
+		for _, resources := range services {
+			for _, entry := range resources {
+				for _, ref := range i.References(entry.TypeName) {
+					dependents[ref.Target] = append(dependents[ref.Target], ref)
+				}
+			}
+		}
+	}
+	for _, refs := range dependents {
+		sort.Slice(refs, func(a, b int) bool {
+			if refs[a].TypeName != refs[b].TypeName {
+				return refs[a].TypeName < refs[b].TypeName
+			}
+			return refs[a].Path < refs[b].Path
+		})
+	}
+	return dependents
+}
+
+// ReferenceValues returns the identifiers at a reference property's path in a resource's properties, which may be a
+// single value or a list.
+func ReferenceValues(props map[string]interface{}, path string) []string {
+	v, ok := GetPath(props, path)
+	if !ok {
+		return nil
+	}
+	var values []string
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+	case string:
+		values = append(values, v)
+	}
+	return values
+}
@@ -2,6 +2,7 @@ package data
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"io/fs"
@@ -14,7 +15,8 @@ import (
 )
 
 const (
-	schemaDir = "~/.cloudctl/schemas/"
+	schemaDir                 = "~/.cloudctl/schemas/"
+	provisioningTypeImmutable = "IMMUTABLE"
 )
 
 type CfnSchema struct {
@@ -33,6 +35,8 @@ type CfnSchema struct {
 	AdditionalIdentifiers [][]string             `json:"additionalIdentifiers"`
 	Handlers              CfnSchemaHandlers      `json:"handlers"`
 	TypeConfiguration     map[string]interface{} `json:"typeConfiguration"`
+	// ProvisioningType is not part of the registry schema, it is recorded from the type's registry entry
+	ProvisioningType string `json:"provisioningType,omitempty"`
 }
 
 type CfnSchemaHandlersPermissions struct {
@@ -47,6 +51,23 @@ type CfnSchemaHandlers struct {
 	List   CfnSchemaHandlersPermissions `json:"list"`
 }
 
+// Permissions returns the IAM actions the handler for verb (create, read, update, delete or list) needs.
+func (h CfnSchemaHandlers) Permissions(verb string) []string {
+	switch verb {
+	case "create":
+		return h.Create.Permissions
+	case "read":
+		return h.Read.Permissions
+	case "update":
+		return h.Update.Permissions
+	case "delete":
+		return h.Delete.Permissions
+	case "list":
+		return h.List.Permissions
+	}
+	return nil
+}
+
 func (s CfnSchema) ToJsonString() (*string, error) {
 	jsonB, err := json.Marshal(s)
 	if err != nil {
@@ -63,27 +84,21 @@ func ParseSchemas() (*map[string]CfnSchema, error) {
 		return nil, err
 	}
 	err = filepath.Walk(*schemasPath, func(path string, info fs.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
-		}
-		jsonFile, err := os.Open(path)
 		if err != nil {
-			fmt.Printf("ERROR: cannot open %q: %q\n", path, err.Error())
+			return err
+		}
+		if info.IsDir() {
 			return nil
 		}
-		defer jsonFile.Close()
-		byteValue, err := ioutil.ReadAll(jsonFile)
+		byteValue, err := ioutil.ReadFile(path)
 		if err != nil {
-			fmt.Printf("ERROR: cannot read %q: %q\n", path, err.Error())
-			return nil
+			return fmt.Errorf("reading %q: %w", path, err)
 		}
-		var schema CfnSchema
-		err = json.Unmarshal(byteValue, &schema)
+		schema, err := ParseSchema(byteValue)
 		if err != nil {
-			fmt.Printf("ERROR: cannot parse %q: %q\n", path, err.Error())
-			return nil
+			return fmt.Errorf("parsing %q: %w", path, err)
 		}
-		schemas[schema.TypeName] = schema
+		schemas[schema.TypeName] = *schema
 		return nil
 	})
 	if err != nil {
@@ -92,7 +107,22 @@ func ParseSchemas() (*map[string]CfnSchema, error) {
 	return &schemas, nil
 }
 
+func ParseSchema(schemaBytes []byte) (*CfnSchema, error) {
+	var schema CfnSchema
+	err := json.Unmarshal(schemaBytes, &schema)
+	if err != nil {
+		return nil, err
+	}
+	if schema.TypeName == "" {
+		return nil, errors.New("schema does not contain a typeName")
+	}
+	return &schema, nil
+}
+
 func (s CfnSchema) IsUpdatable() bool {
+	if s.ProvisioningType == provisioningTypeImmutable {
+		return false
+	}
 	var properties []string
 	for k, _ := range s.Properties {
 		properties = append(properties, k)
@@ -114,6 +144,44 @@ func (s CfnSchema) IsUpdatable() bool {
 	return false
 }
 
+// PrimaryIdentifierNames returns the property names that make up the primary identifier, in identifier order.
+func (s CfnSchema) PrimaryIdentifierNames() []string {
+	var names []string
+	for _, p := range s.PrimaryIdentifier {
+		names = append(names, strings.Replace(p, "/properties/", "", 1))
+	}
+	return names
+}
+
+// IdentifierFormat describes the format of the primary identifier, eg. RestApiId|StageName.
+func (s CfnSchema) IdentifierFormat() string {
+	return strings.Join(s.PrimaryIdentifierNames(), "|")
+}
+
+// BuildIdentifier assembles a primary identifier from its named parts in the order given by the schema.
+func (s CfnSchema) BuildIdentifier(parts map[string]string) (string, error) {
+	names := s.PrimaryIdentifierNames()
+	for name := range parts {
+		if !Contains(names, name) {
+			return "", fmt.Errorf("%q is not part of the %s identifier, expected %s", name, s.TypeName, s.IdentifierFormat())
+		}
+	}
+	var values []string
+	var missing []string
+	for _, name := range names {
+		v, ok := parts[name]
+		if !ok || v == "" {
+			missing = append(missing, name)
+			continue
+		}
+		values = append(values, v)
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing identifier parts %s, %s identifiers are in the format %s", strings.Join(missing, ", "), s.TypeName, s.IdentifierFormat())
+	}
+	return strings.Join(values, "|"), nil
+}
+
 func (s CfnSchema) IsConfigurable() bool {
 	if s.TypeConfiguration == nil {
 		return false
@@ -266,7 +334,7 @@ func NewProp(name string, iface interface{}, schema CfnSchema, parent *property,
 	}
 	prop := property{
 		Name:         name,
-		Type:         propMap["type"].(string),
+		Type:         propertyType(propMap),
 		Depth:        depth,
 		Required:     false,
 		WriteOnly:    false,
@@ -297,7 +365,8 @@ func NewProp(name string, iface interface{}, schema CfnSchema, parent *property,
 	}
 	if prop.Type == "object" {
 		var children YamlDoc
-		for n, i := range iface.(map[string]interface{})["properties"].(map[string]interface{}) {
+		childProps, _ := propMap["properties"].(map[string]interface{})
+		for n, i := range childProps {
 			switch v := i.(type) {
 			case map[string]interface{}:
 				children = append(children, NewProp(n, i, schema, &prop, depth+1))
@@ -307,54 +376,63 @@ func NewProp(name string, iface interface{}, schema CfnSchema, parent *property,
 		}
 		children.Sort()
 		prop.Children = &children
-	} else if prop.Type == "array" {
-		items := NewProp("", iface.(map[string]interface{})["items"], schema, &prop, depth+1)
+	} else if prop.Type == "array" && propMap["items"] != nil {
+		items := NewProp("", propMap["items"], schema, &prop, depth+1)
 		prop.ItemProperty = &items
 	}
 	return prop
 }
 
+// propertyType returns the json schema type of a property. Properties that allow several types use the first one, and
+// properties without a type are treated as objects if they have properties and strings otherwise.
+func propertyType(propMap map[string]interface{}) string {
+	switch t := propMap["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		if len(t) > 0 {
+			if s, ok := t[0].(string); ok {
+				return s
+			}
+		}
+	}
+	if _, ok := propMap["properties"]; ok {
+		return "object"
+	}
+	return "string"
+}
+
 func Edit(initialContent string, fileExt string) ([]byte, error) {
 	tmpFile, err := ioutil.TempFile(os.TempDir(), "cloudctl-*."+fileExt)
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		return nil, err
 	}
+	defer os.Remove(tmpFile.Name())
 	_, err = tmpFile.Write([]byte(initialContent))
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		_ = tmpFile.Close()
+		return nil, err
 	}
 	err = tmpFile.Close()
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		return nil, err
 	}
 	editor := exec.Command("vim", tmpFile.Name())
 	editor.Stdin = os.Stdin
 	editor.Stdout = os.Stdout
 	editor.Stderr = os.Stderr
-	err = editor.Start()
+	err = editor.Run()
 	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
-		return nil, err
-	}
-	err = editor.Wait()
-	if err != nil {
-		fmt.Printf("Error while editing. Error: %s\n", err.Error())
+		return nil, fmt.Errorf("editing %s: %w", tmpFile.Name(), err)
 	}
 	readFile, err := ioutil.ReadFile(tmpFile.Name())
 	if err != nil {
-		fmt.Printf("ERROR: %s", err.Error())
 		return nil, err
 	}
 	desiredState := map[string]interface{}{}
 	err = yaml.Unmarshal(readFile, desiredState)
 	if err != nil {
-		fmt.Printf("ERROR: %s", err.Error())
-		return nil, err
-	}
-	jsonBytes, err := json.Marshal(desiredState)
-	if err != nil {
-		fmt.Printf("ERROR: %s", err.Error())
 		return nil, err
 	}
-	return jsonBytes, nil
+	return json.Marshal(desiredState)
 }
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+// seedSchemas is a small bundle of core resource types that is imported when cloudctl is run without a schema cache,
+// so that the most common types can be used before the first upgrade. The files are in the same format as an
+// extracted bundle created with "cloudctl schemas export".
+//
+//go:embed seed/*.json
+var seedSchemas embed.FS
+
+// CacheReady reports whether a schema cache exists and contains at least one schema.
+func CacheReady() bool {
+	cachePath, err := absPath(cacheDir)
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(*cachePath + cacheFilename); err != nil {
+		return false
+	}
+	c, err := NewCache(CacheROMode)
+	if err != nil {
+		return false
+	}
+	defer c.cache.Close()
+	schemaList, err := c.GetList(schemaListKey)
+	return err == nil && len(*schemaList) > 0
+}
+
+// SeedCache imports the embedded core schemas into the cache. Returns the number of imported schemas.
+func SeedCache() (int, error) {
+	files := map[string][]byte{}
+	entries, err := fs.ReadDir(seedSchemas, "seed")
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		b, err := seedSchemas.ReadFile("seed/" + entry.Name())
+		if err != nil {
+			return 0, err
+		}
+		files[entry.Name()] = b
+	}
+	return importSchemaFiles(files)
+}
@@ -3,8 +3,8 @@ package aws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/alecthomas/chroma/quick"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -13,7 +13,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/cheggaaa/pb/v3"
-	"gopkg.in/yaml.v3"
 	"log"
 	"os"
 	"sync"
@@ -21,11 +20,51 @@ import (
 )
 
 var noWaitSleep = 3 * time.Second
+
+// ServiceRoleArn is passed as the RoleArn of every cloud control request when it is set, the resource handlers then
+// run as the service role instead of with the caller's credentials.
+var ServiceRoleArn string
 var bar *pb.ProgressBar
 
-func FetchSchemas() (*map[string][]byte, error) {
-	schemas := map[string][]byte{}
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRetryer(func() aws.Retryer {
+// TypeVersion identifies the registry version of a resource type, it is compared against the version recorded in the
+// cache to decide whether a schema needs to be downloaded again.
+type TypeVersion struct {
+	TypeName         string
+	TypeArn          string
+	DefaultVersionId string
+	// VersionId is only set when a specific, possibly non-default, version of a private type was requested
+	VersionId        string
+	ProvisioningType string
+	LastUpdated      time.Time
+}
+
+// TypeSchema is a schema downloaded from the registry along with the version it was downloaded at.
+type TypeSchema struct {
+	TypeVersion
+	Schema []byte
+}
+
+// typeSources are the registry listings that make up the schema cache. Category filters must match the visibility,
+// private types are those registered in the account and third party types that have been activated in it.
+var typeSources = []struct {
+	visibility types.Visibility
+	category   types.Category
+}{
+	{types.VisibilityPublic, types.CategoryAwsTypes},
+	{types.VisibilityPublic, types.CategoryThirdParty},
+	{types.VisibilityPrivate, types.CategoryRegistered},
+	{types.VisibilityPrivate, types.CategoryActivated},
+}
+
+// provisioningTypes excludes NON_PROVISIONABLE types, which cannot be managed through Cloud Control. IMMUTABLE types
+// support everything except update.
+var provisioningTypes = []types.ProvisioningType{
+	types.ProvisioningTypeFullyMutable,
+	types.ProvisioningTypeImmutable,
+}
+
+func newCfnClient(ctx context.Context) (*cloudformation.Client, error) {
+	cfg, err := loadConfig(ctx, config.WithRetryer(func() aws.Retryer {
 		return retry.NewStandard(func(opts *retry.StandardOptions) {
 			opts.MaxAttempts = 20
 			opts.MaxBackoff = 60 * time.Second
@@ -35,59 +74,140 @@ func FetchSchemas() (*map[string][]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	cfn := cloudformation.NewFromConfig(cfg)
-	categories := []types.Category{"THIRD_PARTY", "AWS_TYPES"}
-	var typeArns []*string
-	for _, c := range categories {
-		params := &cloudformation.ListTypesInput{
-			Filters: &types.TypeFilters{
-				Category: c,
-			},
-			ProvisioningType: "FULLY_MUTABLE",
-			Type:             "RESOURCE",
-			Visibility:       "PUBLIC",
-		}
-
-		paginator := cloudformation.NewListTypesPaginator(cfn, params, func(o *cloudformation.ListTypesPaginatorOptions) {})
+	return cloudformation.NewFromConfig(cfg), nil
+}
 
-		for paginator.HasMorePages() {
-			output, err := paginator.NextPage(context.TODO())
-			if err != nil {
-				log.Printf("error: %v", err)
-				return nil, err
+// ListTypes returns the current version of every resource type in the registry, keyed by type name. Only summaries
+// are fetched, schemas are downloaded separately with FetchSchemas.
+func ListTypes(ctx context.Context) (*map[string]TypeVersion, error) {
+	cfn, err := newCfnClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]TypeVersion{}
+	for _, source := range typeSources {
+		for _, provisioningType := range provisioningTypes {
+			params := &cloudformation.ListTypesInput{
+				Filters: &types.TypeFilters{
+					Category: source.category,
+				},
+				ProvisioningType: provisioningType,
+				Type:             types.RegistryTypeResource,
+				Visibility:       source.visibility,
 			}
-			for _, value := range output.TypeSummaries {
-				typeArns = append(typeArns, value.TypeArn)
+
+			paginator := cloudformation.NewListTypesPaginator(cfn, params, func(o *cloudformation.ListTypesPaginatorOptions) {})
+
+			for paginator.HasMorePages() {
+				output, err := paginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("error: %v", err)
+					return nil, err
+				}
+				for _, value := range output.TypeSummaries {
+					v := TypeVersion{
+						TypeName:         *value.TypeName,
+						TypeArn:          *value.TypeArn,
+						ProvisioningType: string(provisioningType),
+					}
+					if value.DefaultVersionId != nil {
+						v.DefaultVersionId = *value.DefaultVersionId
+					}
+					if value.LastUpdated != nil {
+						v.LastUpdated = *value.LastUpdated
+					}
+					// private listings come after public ones, so activated types end up with their account specific arn
+					versions[v.TypeName] = v
+				}
 			}
 		}
 	}
+	return &versions, nil
+}
+
+// FetchSchemas downloads the schemas for the supplied type versions, keyed by type name.
+func FetchSchemas(ctx context.Context, typeVersions []TypeVersion) (*map[string]TypeSchema, error) {
+	schemas := map[string]TypeSchema{}
+	if len(typeVersions) == 0 {
+		return &schemas, nil
+	}
+	cfn, err := newCfnClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var typeArns []*string
+	for i := range typeVersions {
+		typeArns = append(typeArns, &typeVersions[i].TypeArn)
+	}
 	bar = pb.StartNew(len(typeArns))
-	typeDescriptions, err := asyncCfnDescribeType(*cfn, typeArns)
+	typeDescriptions, err := asyncCfnDescribeType(ctx, *cfn, typeArns)
 	if err != nil {
 		log.Printf("error: %v", err)
 		return nil, err
 	}
 	for _, value := range typeDescriptions {
-		schemas[*value.TypeName] = []byte(*value.Schema)
+		schemas[*value.TypeName] = newTypeSchema(value)
 	}
 	bar.Finish()
 	return &schemas, nil
 }
 
-func NewCcClient() (*cloudcontrol.Client, error) {
+// FetchSchema downloads a single resource type's schema. An empty versionId fetches the default version, otherwise
+// the given version of a private type is fetched.
+func FetchSchema(ctx context.Context, typeName string, versionId string) (*TypeSchema, error) {
+	cfn, err := newCfnClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params := &cloudformation.DescribeTypeInput{
+		Type:     types.RegistryTypeResource,
+		TypeName: &typeName,
+	}
+	if versionId != "" {
+		params.VersionId = &versionId
+	}
+	typeDesc, err := cfn.DescribeType(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	schema := newTypeSchema(typeDesc)
+	schema.VersionId = versionId
+	return &schema, nil
+}
+
+func newTypeSchema(typeDesc *cloudformation.DescribeTypeOutput) TypeSchema {
+	s := TypeSchema{
+		TypeVersion: TypeVersion{
+			TypeName:         *typeDesc.TypeName,
+			ProvisioningType: string(typeDesc.ProvisioningType),
+		},
+		Schema: []byte(*typeDesc.Schema),
+	}
+	if typeDesc.Arn != nil {
+		s.TypeArn = *typeDesc.Arn
+	}
+	if typeDesc.DefaultVersionId != nil {
+		s.DefaultVersionId = *typeDesc.DefaultVersionId
+	}
+	if typeDesc.LastUpdated != nil {
+		s.LastUpdated = *typeDesc.LastUpdated
+	}
+	return s
+}
+
+func NewCcClient(ctx context.Context) (*cloudcontrol.Client, error) {
 	logMode := aws.LogRetries
 	if os.Getenv("CLOUDCTL_DEBUG") != "" {
 		logMode |= aws.LogRequestWithBody | aws.LogResponseWithBody
 	}
-	cfg, err := config.LoadDefaultConfig(
-		context.TODO(),
+	cfg, err := loadConfig(
+		ctx,
 		config.WithRetryer(func() aws.Retryer {
 			return retry.AddWithMaxAttempts(retry.NewStandard(), 20)
 		}),
 		config.WithClientLogMode(logMode),
 	)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
 		return nil, err
 	}
 	cc := cloudcontrol.NewFromConfig(cfg, func(options *cloudcontrol.Options) {
@@ -96,28 +216,74 @@ func NewCcClient() (*cloudcontrol.Client, error) {
 	return cc, nil
 }
 
-func ListResource(typeName string) (*[]typesCC.ResourceDescription, error) {
-	cc, err := NewCcClient()
+// Region returns the region from the configuration AWS calls are made with, or an empty string if none is configured.
+func Region(ctx context.Context) string {
+	cfg, err := loadConfig(ctx)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
-		return nil, err
+		return ""
 	}
-	resp, err := cc.ListResources(context.TODO(), &cloudcontrol.ListResourcesInput{TypeName: &typeName})
+	return cfg.Region
+}
+
+// ListResource lists typeName's resources.
+func ListResource(ctx context.Context, typeName string) (*[]typesCC.ResourceDescription, error) {
+	cc, err := NewCcClient(ctx)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
 		return nil, err
 	}
-	return &resp.ResourceDescriptions, nil
+	var resources []typesCC.ResourceDescription
+	paginator := cloudcontrol.NewListResourcesPaginator(cc, &cloudcontrol.ListResourcesInput{TypeName: &typeName, RoleArn: serviceRole()})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, newApiError("list", typeName, "", err)
+		}
+		resources = append(resources, resp.ResourceDescriptions...)
+	}
+	return &resources, nil
+}
+
+// DeleteResource deletes a resource. Deletes are idempotent, so operations that fail transiently are retried, and a
+// retry that finds the resource gone means the failed attempt deleted it after all. Attempts share one progress line
+// and only the outcome of the last one is reported.
+func DeleteResource(ctx context.Context, cc *cloudcontrol.Client, typeName string, id string, async bool) error {
+	var op *operation
+	start := func() *operation {
+		if op == nil {
+			op = startOperation("delete", typeName, id)
+		}
+		return op
+	}
+	var pe *typesCC.ProgressEvent
+	retrying := false
+	err := withRetries(ctx, func() error {
+		var err error
+		pe, err = deleteResource(ctx, cc, typeName, id, async, start)
+		if retrying && errors.Is(err, ErrNotFound) {
+			pe = &typesCC.ProgressEvent{OperationStatus: typesCC.OperationStatusSuccess}
+			return nil
+		}
+		retrying = true
+		return err
+	})
+	if op != nil {
+		op.finish(ctx)
+	}
+	if pe != nil {
+		printResult("delete", typeName, id, *pe, async)
+	}
+	return err
 }
 
-func DeleteResource(cc *cloudcontrol.Client, typeName string, id string, async bool) error {
+// deleteResource makes one attempt at deleting a resource, returning the last progress event if the request was made.
+// start returns the operation that shows its progress, it's only called once the request is accepted.
+func deleteResource(ctx context.Context, cc *cloudcontrol.Client, typeName string, id string, async bool, start func() *operation) (*typesCC.ProgressEvent, error) {
 	resp, err := cc.DeleteResource(
-		context.TODO(),
-		&cloudcontrol.DeleteResourceInput{TypeName: &typeName, Identifier: &id},
+		ctx,
+		&cloudcontrol.DeleteResourceInput{TypeName: &typeName, Identifier: &id, RoleArn: serviceRole()},
 	)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
-		return err
+		return nil, newApiError("delete", typeName, id, err)
 	}
 	// nil timeout will wait until operation completes
 	var timeout *time.Time = nil
@@ -126,59 +292,83 @@ func DeleteResource(cc *cloudcontrol.Client, typeName string, id string, async b
 		// most errors come back really quickly, so we wait a bit even if wait is disabled
 		timeout = &timeoutTime
 	}
-	pe, err := waitForComplete(*cc, *resp.ProgressEvent, timeout)
+	pe, err := waitForComplete(ctx, *cc, *resp.ProgressEvent, timeout, start())
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
-		return err
+		return nil, err
 	}
 	if pe.OperationStatus == typesCC.OperationStatusFailed {
-		fmt.Printf("ERROR: %s with identifier %q failed. [%s] %s\n", typeName, id, pe.ErrorCode, *pe.StatusMessage)
-	}
-	fmt.Printf("%s delete %s for resource with the identifier %q\n", typeName, pe.OperationStatus, id)
-	if async && !isFinished(*pe) {
-		fmt.Printf("Request token: %s\n", *pe.RequestToken)
+		return pe, newOperationError("delete", typeName, id, *pe)
 	}
-	return nil
+	return pe, nil
 }
 
-func ReadResource(cc *cloudcontrol.Client, typeName string, id string, async bool) error {
+// GetResource returns the current properties of a resource as a json string.
+func GetResource(ctx context.Context, cc *cloudcontrol.Client, typeName string, id string) (*string, error) {
 	resp, err := cc.GetResource(
-		context.TODO(),
-		&cloudcontrol.GetResourceInput{TypeName: &typeName, Identifier: &id},
+		ctx,
+		&cloudcontrol.GetResourceInput{TypeName: &typeName, Identifier: &id, RoleArn: serviceRole()},
 	)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
-		return err
+		return nil, newApiError("read", typeName, id, err)
 	}
-	properties := *resp.ResourceDescription.Properties
-	jsonProps := map[string]interface{}{}
-	err = json.Unmarshal([]byte(properties), &jsonProps)
-	if err != nil {
-		fmt.Printf("ERROR: %s", err.Error())
-		return err
+	return resp.ResourceDescription.Properties, nil
+}
+
+// replayTolerance allows for the clock difference with cloud control when deciding whether a create request was made
+// before the call that returned it.
+const replayTolerance = time.Minute
+
+// CreateResource creates a resource and returns its final progress event, or the latest one if async. Requests with
+// the same clientToken are only carried out once, an empty token is replaced with a random one. A token that was
+// used before the call returns the earlier request, which is warned about.
+func CreateResource(ctx context.Context, cc *cloudcontrol.Client, typeName string, desiredState *string, clientToken string, async bool) (*typesCC.ProgressEvent, error) {
+	input := &cloudcontrol.CreateResourceInput{TypeName: &typeName, DesiredState: desiredState, RoleArn: serviceRole()}
+	if clientToken != "" {
+		input.ClientToken = &clientToken
 	}
-	yamlDoc, err := yaml.Marshal(jsonProps)
+	requested := time.Now()
+	resp, err := cc.CreateResource(ctx, input)
 	if err != nil {
-		fmt.Printf("ERROR: %s", err.Error())
-		return err
+		return nil, newApiError("create", typeName, "", err)
+	}
+	if started := resp.ProgressEvent.EventTime; clientToken != "" && started != nil && started.Before(requested.Add(-replayTolerance)) {
+		fmt.Fprintf(os.Stderr, "WARNING: client token %q was used by a %s create at %s, its request is shown instead, pass another --client-token to create it again\n",
+			clientToken, typeName, started.Local().Format(time.RFC3339))
 	}
-	err = quick.Highlight(os.Stdout, string(yamlDoc), "yaml", "terminal16m", "pygments")
+	op := startOperation("create", typeName, "")
+	// nil timeout will wait until operation completes
+	var timeout *time.Time = nil
+	timeoutTime := time.Now().Add(noWaitSleep)
+	if async {
+		// most errors come back really quickly, so we wait a bit even if wait is disabled
+		timeout = &timeoutTime
+	}
+	pe, err := waitForComplete(ctx, *cc, *resp.ProgressEvent, timeout, op)
+	op.finish(ctx)
 	if err != nil {
-		fmt.Printf("ERROR: %s", err.Error())
-		return err
+		return nil, err
 	}
-	return nil
+	id := ""
+	if pe.Identifier != nil {
+		id = *pe.Identifier
+	}
+	printResult("create", typeName, id, *pe, async)
+	if pe.OperationStatus == typesCC.OperationStatusFailed {
+		return pe, newOperationError("create", typeName, "", *pe)
+	}
+	return pe, nil
 }
 
-func CreateResource(cc *cloudcontrol.Client, typeName string, desiredState *string, async bool) error {
-	resp, err := cc.CreateResource(
-		context.TODO(),
-		&cloudcontrol.CreateResourceInput{TypeName: &typeName, DesiredState: desiredState},
+// UpdateResource applies a JSON patch to a resource and returns its final progress event, or the latest one if async.
+func UpdateResource(ctx context.Context, cc *cloudcontrol.Client, typeName string, id string, patchDocument *string, async bool) (*typesCC.ProgressEvent, error) {
+	resp, err := cc.UpdateResource(
+		ctx,
+		&cloudcontrol.UpdateResourceInput{TypeName: &typeName, Identifier: &id, PatchDocument: patchDocument, RoleArn: serviceRole()},
 	)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
-		return err
+		return nil, newApiError("update", typeName, id, err)
 	}
+	op := startOperation("update", typeName, id)
 	// nil timeout will wait until operation completes
 	var timeout *time.Time = nil
 	timeoutTime := time.Now().Add(noWaitSleep)
@@ -186,28 +376,79 @@ func CreateResource(cc *cloudcontrol.Client, typeName string, desiredState *stri
 		// most errors come back really quickly, so we wait a bit even if wait is disabled
 		timeout = &timeoutTime
 	}
-	pe, err := waitForComplete(*cc, *resp.ProgressEvent, timeout)
+	pe, err := waitForComplete(ctx, *cc, *resp.ProgressEvent, timeout, op)
+	op.finish(ctx)
 	if err != nil {
-		fmt.Printf("ERROR: %q", err.Error())
-		return err
+		return nil, err
 	}
+	printResult("update", typeName, id, *pe, async)
 	if pe.OperationStatus == typesCC.OperationStatusFailed {
-		if pe.Identifier == nil {
-			fmt.Printf("ERROR: %s failed without returning an identifier. [%s] %s\n", typeName, pe.ErrorCode, *pe.StatusMessage)
-		} else {
-			fmt.Printf("ERROR: %s with identifier %q failed. [%s] %s\n", typeName, pe.Identifier, pe.ErrorCode, *pe.StatusMessage)
-		}
+		return pe, newOperationError("update", typeName, id, *pe)
+	}
+	return pe, nil
+}
+
+// serviceRole returns the RoleArn for cloud control requests, nil uses the caller's credentials.
+func serviceRole() *string {
+	if ServiceRoleArn == "" {
+		return nil
+	}
+	return &ServiceRoleArn
+}
 
+// asServiceRole describes the service role an operation ran as, for its result line.
+func asServiceRole() string {
+	if ServiceRoleArn == "" {
+		return ""
 	}
-	if pe.Identifier == nil {
-		fmt.Printf("%s create %s for resource with no identifier\n", typeName, pe.OperationStatus)
+	return " as service role " + ServiceRoleArn
+}
+
+// JsonOutput prints the results of operations as one JSON object per line, it is set when the output is json.
+var JsonOutput bool
+
+// OperationResult is the result of an operation as it is printed with json output.
+type OperationResult struct {
+	Operation  string `json:"Operation"`
+	TypeName   string `json:"TypeName"`
+	Identifier string `json:"Identifier,omitempty"`
+	Status     string `json:"Status"`
+	// RequestToken is included while the operation is still in progress, to follow it with
+	RequestToken string `json:"RequestToken,omitempty"`
+	// ServiceRole is the role the resource handler ran as
+	ServiceRole string `json:"ServiceRole,omitempty"`
+}
+
+// printResult prints the result line of an operation. With json output failed operations are left for their error
+// to report.
+func printResult(verb string, typeName string, id string, pe typesCC.ProgressEvent, async bool) {
+	pending := async && !isFinished(pe)
+	if JsonOutput {
+		if pe.OperationStatus == typesCC.OperationStatusFailed {
+			return
+		}
+		result := OperationResult{
+			Operation:   verb,
+			TypeName:    typeName,
+			Identifier:  id,
+			Status:      string(pe.OperationStatus),
+			ServiceRole: ServiceRoleArn,
+		}
+		if pending && pe.RequestToken != nil {
+			result.RequestToken = *pe.RequestToken
+		}
+		b, _ := json.Marshal(result)
+		progress.printf("%s\n", b)
+		return
+	}
+	if id == "" {
+		progress.printf("%s %s %s for resource with no identifier%s\n", typeName, verb, pe.OperationStatus, asServiceRole())
 	} else {
-		fmt.Printf("%s create %s for resource with the identifier %q\n", typeName, pe.OperationStatus, *pe.Identifier)
+		progress.printf("%s %s %s for resource with the identifier %q%s\n", typeName, verb, pe.OperationStatus, id, asServiceRole())
 	}
-	if async && !isFinished(*pe) {
-		fmt.Printf("Request token: %s\n", *pe.RequestToken)
+	if pending {
+		progress.printf("Request token: %s\n", *pe.RequestToken)
 	}
-	return nil
 }
 
 func isFinished(pe typesCC.ProgressEvent) bool {
@@ -224,27 +465,44 @@ func isFinished(pe typesCC.ProgressEvent) bool {
 	return false
 }
 
-func waitForComplete(cc cloudcontrol.Client, pe typesCC.ProgressEvent, timeout *time.Time) (*typesCC.ProgressEvent, error) {
-	if !isFinished(pe) {
+// waitForComplete polls the status of pe's request until it finishes, timeout passes or ctx is done. Polls back off
+// exponentially and are rate limited across goroutines, see poll.go.
+func waitForComplete(ctx context.Context, cc cloudcontrol.Client, pe typesCC.ProgressEvent, timeout *time.Time, op *operation) (*typesCC.ProgressEvent, error) {
+	delays := newBackoff()
+	for {
+		op.update(pe)
+		if isFinished(pe) {
+			return &pe, nil
+		}
+		delay := delays.next(pe)
 		if timeout != nil {
-			if time.Now().After(*timeout) {
+			remaining := time.Until(*timeout)
+			if remaining <= 0 {
 				return &pe, nil
 			}
+			if delay > remaining {
+				delay = remaining
+			}
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return &pe, err
+		}
+		if err := pollLimiter.wait(ctx); err != nil {
+			return &pe, err
 		}
-		time.Sleep(2 * time.Second)
 		resp, err := cc.GetResourceRequestStatus(
-			context.TODO(),
+			ctx,
 			&cloudcontrol.GetResourceRequestStatusInput{RequestToken: pe.RequestToken},
 		)
 		if err != nil {
-			return resp.ProgressEvent, err
+			return &pe, err
 		}
-		return waitForComplete(cc, *resp.ProgressEvent, timeout)
+		pe = *resp.ProgressEvent
 	}
-	return &pe, nil
 }
 
-const concurrentAwsCalls = 4
+// Parallelism is the number of concurrent AWS calls made by bulk operations.
+var Parallelism = 4
 
 func streamInputs(done <-chan struct{}, inputs []*string) <-chan string {
 	inputCh := make(chan string)
@@ -261,8 +519,8 @@ func streamInputs(done <-chan struct{}, inputs []*string) <-chan string {
 	return inputCh
 }
 
-func describeType(client cloudformation.Client, arn string) (*cloudformation.DescribeTypeOutput, error) {
-	typeDesc, err := client.DescribeType(context.TODO(), &cloudformation.DescribeTypeInput{
+func describeType(ctx context.Context, client cloudformation.Client, arn string) (*cloudformation.DescribeTypeOutput, error) {
+	typeDesc, err := client.DescribeType(ctx, &cloudformation.DescribeTypeInput{
 		Arn: &arn,
 	})
 	if err != nil {
@@ -278,21 +536,21 @@ type result struct {
 	err      error
 }
 
-func asyncCfnDescribeType(client cloudformation.Client, typeArns []*string) ([]*cloudformation.DescribeTypeOutput, error) {
+func asyncCfnDescribeType(ctx context.Context, client cloudformation.Client, typeArns []*string) ([]*cloudformation.DescribeTypeOutput, error) {
 	done := make(chan struct{})
 	defer close(done)
 
 	inputCh := streamInputs(done, typeArns)
 
 	var wg sync.WaitGroup
-	wg.Add(concurrentAwsCalls)
+	wg.Add(Parallelism)
 
 	resultCh := make(chan result)
 
-	for i := 0; i < concurrentAwsCalls; i++ {
+	for i := 0; i < Parallelism; i++ {
 		go func() {
 			for input := range inputCh {
-				resp, err := describeType(client, input)
+				resp, err := describeType(ctx, client, input)
 				resultCh <- result{resp, err}
 			}
 			wg.Done()
@@ -315,31 +573,33 @@ func asyncCfnDescribeType(client cloudformation.Client, typeArns []*string) ([]*
 }
 
 type deleteResourceErrors struct {
-	id  string
-	err error
+	index int
+	err   error
 }
 
-func AsyncCcDeleteResource(client cloudcontrol.Client, typeName string, resourceIds []string, async bool) {
-	done := make(chan struct{})
-	defer close(done)
-
-	var strSlice []*string
-
-	for _, i := range resourceIds {
-		strSlice = append(strSlice, &i)
-	}
-	inputCh := streamInputs(done, strSlice)
+// AsyncCcDeleteResource deletes resources concurrently. The errors are returned in the same order as resourceIds, nil
+// for the resources that were deleted.
+func AsyncCcDeleteResource(ctx context.Context, client cloudcontrol.Client, typeName string, resourceIds []string, async bool) []error {
+	progress.start()
+	defer progress.stop()
+	inputCh := make(chan int)
+	go func() {
+		defer close(inputCh)
+		for i := range resourceIds {
+			inputCh <- i
+		}
+	}()
 
 	var wg sync.WaitGroup
-	wg.Add(concurrentAwsCalls)
+	wg.Add(Parallelism)
 
 	resultCh := make(chan deleteResourceErrors)
 
-	for i := 0; i < concurrentAwsCalls; i++ {
+	for i := 0; i < Parallelism; i++ {
 		go func() {
-			for input := range inputCh {
-				err := DeleteResource(&client, typeName, input, async)
-				resultCh <- deleteResourceErrors{input, err}
+			for index := range inputCh {
+				err := DeleteResource(ctx, &client, typeName, resourceIds[index], async)
+				resultCh <- deleteResourceErrors{index, err}
 			}
 			wg.Done()
 		}()
@@ -349,23 +609,69 @@ func AsyncCcDeleteResource(client cloudcontrol.Client, typeName string, resource
 		wg.Wait()
 		close(resultCh)
 	}()
+	errs := make([]error, len(resourceIds))
 	for e := range resultCh {
-		if e.err != nil {
-			fmt.Printf("deleting %s with identifier %s failed: %s\n", typeName, e.id, e.err.Error())
-		}
+		errs[e.index] = e.err
 	}
+	return errs
 }
 
-func AsyncCcCreateResource(client cloudcontrol.Client, typeName string, desiredState string, async bool) {
-	err := CreateResource(&client, typeName, &desiredState, async)
-	if err != nil {
-		fmt.Printf("creating %s failed: %s", typeName, err.Error())
-	}
+func AsyncCcCreateResource(ctx context.Context, client cloudcontrol.Client, typeName string, desiredState string, clientToken string, async bool) error {
+	progress.start()
+	defer progress.stop()
+	_, err := CreateResource(ctx, &client, typeName, &desiredState, clientToken, async)
+	return err
 }
 
-func AsyncCcReadResource(client cloudcontrol.Client, typeName string, id string, async bool) {
-	err := ReadResource(&client, typeName, id, async)
-	if err != nil {
-		fmt.Printf("reading %s failed: %s", typeName, err.Error())
+func AsyncCcUpdateResource(ctx context.Context, client cloudcontrol.Client, typeName string, id string, patchDocument string, async bool) error {
+	progress.start()
+	defer progress.stop()
+	_, err := UpdateResource(ctx, &client, typeName, id, &patchDocument, async)
+	return err
+}
+
+// getResourceResult is the properties of the resource at index in a bulk read, or the error reading them.
+type getResourceResult struct {
+	index      int
+	properties *string
+	err        error
+}
+
+// AsyncCcGetResources reads resources concurrently. The properties and errors are returned in the same order as
+// resourceIds.
+func AsyncCcGetResources(ctx context.Context, client cloudcontrol.Client, typeName string, resourceIds []string) ([]*string, []error) {
+	inputCh := make(chan int)
+	go func() {
+		defer close(inputCh)
+		for i := range resourceIds {
+			inputCh <- i
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(Parallelism)
+
+	resultCh := make(chan getResourceResult)
+
+	for i := 0; i < Parallelism; i++ {
+		go func() {
+			for index := range inputCh {
+				props, err := GetResource(ctx, &client, typeName, resourceIds[index])
+				resultCh <- getResourceResult{index: index, properties: props, err: err}
+			}
+			wg.Done()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+	properties := make([]*string, len(resourceIds))
+	errs := make([]error, len(resourceIds))
+	for result := range resultCh {
+		properties[result.index] = result.properties
+		errs[result.index] = result.err
 	}
+	return properties, errs
 }
@@ -0,0 +1,161 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credentialsExpiryWindow is how long before they expire cached credentials stop being used, so that they don't
+// expire part way through an operation.
+const credentialsExpiryWindow = 5 * time.Minute
+
+// RoleOptions is a role to assume instead of using the default credentials directly.
+type RoleOptions struct {
+	RoleArn    string
+	ExternalId string
+	// MfaSerial is the serial number or arn of the MFA device the role requires a code from
+	MfaSerial string
+	// Duration of the role session, zero uses the STS default of an hour
+	Duration time.Duration
+}
+
+// Role is assumed for every AWS call when its RoleArn is set.
+var Role RoleOptions
+
+// MfaTokenPrompt asks for the current code of the MFA device with serial. Roles that need MFA fail to be assumed when
+// it is nil.
+var MfaTokenPrompt func(serial string) (string, error)
+
+// CredentialsCacheDir is where assumed role credentials are kept until they expire, so that MFA codes are asked for once
+// per session rather than once per command. Credentials are only kept in memory when it is empty.
+var CredentialsCacheDir string
+
+var roleCredentials struct {
+	sync.Mutex
+	role     RoleOptions
+	provider aws.CredentialsProvider
+}
+
+// loadConfig loads the default configuration, with the credentials of Role if one is set.
+func loadConfig(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil || Role.RoleArn == "" {
+		return cfg, err
+	}
+	cfg.Credentials = roleProvider(cfg)
+	if Role.MfaSerial != "" {
+		// prompt for the code now, before any progress bars are drawn over the prompt
+		if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			return cfg, fmt.Errorf("assuming role %s: %w", Role.RoleArn, err)
+		}
+	}
+	return cfg, nil
+}
+
+// roleProvider returns the credentials provider for Role. Clients are created for each command, so the provider is
+// shared between them to assume the role, and prompt for MFA, once.
+func roleProvider(cfg aws.Config) aws.CredentialsProvider {
+	roleCredentials.Lock()
+	defer roleCredentials.Unlock()
+	if roleCredentials.provider != nil && roleCredentials.role == Role {
+		return roleCredentials.provider
+	}
+	role := Role
+	assume := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = fmt.Sprintf("cloudctl-%d", time.Now().Unix())
+		o.Duration = role.Duration
+		if role.ExternalId != "" {
+			o.ExternalID = &role.ExternalId
+		}
+		if role.MfaSerial != "" {
+			o.SerialNumber = &role.MfaSerial
+			o.TokenProvider = func() (string, error) {
+				if MfaTokenPrompt == nil {
+					return "", fmt.Errorf("the role needs an MFA code and prompts are disabled")
+				}
+				return MfaTokenPrompt(role.MfaSerial)
+			}
+		}
+	})
+	var provider aws.CredentialsProvider = assume
+	if CredentialsCacheDir != "" {
+		provider = &fileCredentials{path: filepath.Join(CredentialsCacheDir, role.cacheKey()+".json"), provider: assume}
+	}
+	roleCredentials.role = role
+	roleCredentials.provider = aws.NewCredentialsCache(provider)
+	return roleCredentials.provider
+}
+
+// cacheKey identifies the role's credentials in the cache. The profile is included because the same role may be
+// assumed from different source credentials.
+func (r RoleOptions) cacheKey() string {
+	key := strings.Join([]string{os.Getenv("AWS_PROFILE"), r.RoleArn, r.ExternalId, r.MfaSerial, r.Duration.String()}, "\n")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// fileCredentials keeps the credentials it retrieves in a file that only the user can read, and returns them from
+// there until they are about to expire.
+type fileCredentials struct {
+	path     string
+	provider aws.CredentialsProvider
+}
+
+func (f *fileCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	var creds aws.Credentials
+	if b, err := os.ReadFile(f.path); err == nil && json.Unmarshal(b, &creds) == nil {
+		if creds.CanExpire && time.Now().Add(credentialsExpiryWindow).Before(creds.Expires) {
+			return creds, nil
+		}
+	}
+	creds, err := f.provider.Retrieve(ctx)
+	if err != nil {
+		return creds, err
+	}
+	// failing to cache only means being asked for an MFA code again
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err == nil {
+		if b, err := json.Marshal(creds); err == nil {
+			_ = os.WriteFile(f.path, b, 0600)
+		}
+	}
+	return creds, nil
+}
+
+// Caller returns the region and the access key id of the credentials that AWS calls are made with. Retrieving them
+// doesn't call AWS unless a role has to be assumed.
+func Caller(ctx context.Context) (string, string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return cfg.Region, creds.AccessKeyID, nil
+}
+
+// AccountId returns the account that the current credentials belong to.
+func AccountId(ctx context.Context) (string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return *identity.Account, nil
+}
@@ -0,0 +1,272 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	typesCC "github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"github.com/aws/smithy-go"
+)
+
+// maxRetries is how many times an idempotent operation is retried after a retryable failure.
+const maxRetries = 3
+
+// HandlerErrorCodeClientTokenConflict is the code of a ClientTokenConflictException, which has no handler error code
+// because it is only returned by the api.
+const HandlerErrorCodeClientTokenConflict typesCC.HandlerErrorCode = "ClientTokenConflict"
+
+// HandlerError is a class of failure identified by a cloud control handler error code. Failed operations and api
+// errors match the HandlerError of their code, eg. errors.Is(err, ErrNotFound).
+type HandlerError struct {
+	Code typesCC.HandlerErrorCode
+	// Hint suggests how to fix the problem
+	Hint string
+	// Retryable failures are transient, the same request may succeed when it is made again
+	Retryable bool
+}
+
+func (e *HandlerError) Error() string {
+	return string(e.Code)
+}
+
+var (
+	ErrNotUpdatable = &HandlerError{
+		Code: typesCC.HandlerErrorCodeNotUpdatable,
+		Hint: "a property that can't be updated was changed, delete and recreate the resource to change it",
+	}
+	ErrInvalidRequest = &HandlerError{
+		Code: typesCC.HandlerErrorCodeInvalidRequest,
+		Hint: "the properties were rejected, compare them against the type's schema",
+	}
+	ErrAccessDenied = &HandlerError{
+		Code: typesCC.HandlerErrorCodeAccessDenied,
+		Hint: "the credentials are not allowed to manage this type",
+	}
+	ErrInvalidCredentials = &HandlerError{
+		Code: typesCC.HandlerErrorCodeInvalidCredentials,
+		Hint: "the credentials are invalid or have expired, check them with: aws sts get-caller-identity",
+	}
+	ErrAlreadyExists = &HandlerError{
+		Code: typesCC.HandlerErrorCodeAlreadyExists,
+		Hint: "a resource with the same identifier exists, update it or choose another name",
+	}
+	ErrNotFound = &HandlerError{
+		Code: typesCC.HandlerErrorCodeNotFound,
+		Hint: "the resource does not exist, or exists in another account or region",
+	}
+	ErrResourceConflict = &HandlerError{
+		Code:      typesCC.HandlerErrorCodeResourceConflict,
+		Hint:      "another operation is in progress on the resource, try again once it has finished",
+		Retryable: true,
+	}
+	ErrThrottling = &HandlerError{
+		Code:      typesCC.HandlerErrorCodeThrottling,
+		Hint:      "requests are being throttled, lower --parallelism",
+		Retryable: true,
+	}
+	ErrServiceLimitExceeded = &HandlerError{
+		Code: typesCC.HandlerErrorCodeServiceLimitExceeded,
+		Hint: "an account quota has been reached, delete unused resources or request an increase in Service Quotas",
+	}
+	ErrNotStabilized = &HandlerError{
+		Code: typesCC.HandlerErrorCodeNotStabilized,
+		Hint: "the resource did not reach a stable state, check it in the service's console before retrying",
+	}
+	ErrGeneralServiceException = &HandlerError{
+		Code: typesCC.HandlerErrorCodeGeneralServiceException,
+		Hint: "the service behind the resource returned an error, see its message",
+	}
+	ErrServiceInternalError = &HandlerError{
+		Code:      typesCC.HandlerErrorCodeServiceInternalError,
+		Hint:      "the service behind the resource failed, try again later",
+		Retryable: true,
+	}
+	ErrServiceTimeout = &HandlerError{
+		Code:      typesCC.HandlerErrorCodeServiceTimeout,
+		Hint:      "the service behind the resource timed out, try again later",
+		Retryable: true,
+	}
+	ErrNetworkFailure = &HandlerError{
+		Code:      typesCC.HandlerErrorCodeNetworkFailure,
+		Hint:      "the resource's handler could not reach the service, try again later",
+		Retryable: true,
+	}
+	ErrInternalFailure = &HandlerError{
+		Code:      typesCC.HandlerErrorCodeInternalFailure,
+		Hint:      "the resource's handler failed, try again later or report it to the type's publisher",
+		Retryable: true,
+	}
+	ErrClientTokenConflict = &HandlerError{
+		Code: HandlerErrorCodeClientTokenConflict,
+		Hint: "the client token was used by a different request in the last 36 hours, pass another with --client-token",
+	}
+)
+
+var handlerErrors = map[typesCC.HandlerErrorCode]*HandlerError{}
+
+func init() {
+	for _, e := range []*HandlerError{
+		ErrNotUpdatable, ErrInvalidRequest, ErrAccessDenied, ErrInvalidCredentials, ErrAlreadyExists, ErrNotFound,
+		ErrResourceConflict, ErrThrottling, ErrServiceLimitExceeded, ErrNotStabilized, ErrGeneralServiceException,
+		ErrServiceInternalError, ErrServiceTimeout, ErrNetworkFailure, ErrInternalFailure, ErrClientTokenConflict,
+	} {
+		handlerErrors[e.Code] = e
+	}
+}
+
+// OperationError is an operation that cloud control accepted but reported as FAILED.
+type OperationError struct {
+	Verb          string
+	TypeName      string
+	Identifier    string
+	ErrorCode     typesCC.HandlerErrorCode
+	StatusMessage string
+	RequestToken  string
+}
+
+func (e *OperationError) Error() string {
+	if e.Identifier == "" {
+		return fmt.Sprintf("%s of %s failed without returning an identifier. [%s] %s", e.Verb, e.TypeName, e.ErrorCode, e.StatusMessage)
+	}
+	return fmt.Sprintf("%s of %s with identifier %q failed. [%s] %s", e.Verb, e.TypeName, e.Identifier, e.ErrorCode, e.StatusMessage)
+}
+
+// Unwrap returns the HandlerError of the operation's error code, or nil for codes that aren't known.
+func (e *OperationError) Unwrap() error {
+	if h, ok := handlerErrors[e.ErrorCode]; ok {
+		return h
+	}
+	return nil
+}
+
+func newOperationError(verb string, typeName string, id string, pe typesCC.ProgressEvent) *OperationError {
+	e := &OperationError{Verb: verb, TypeName: typeName, Identifier: id, ErrorCode: pe.ErrorCode}
+	if e.Identifier == "" && pe.Identifier != nil {
+		e.Identifier = *pe.Identifier
+	}
+	if pe.StatusMessage != nil {
+		e.StatusMessage = *pe.StatusMessage
+	}
+	if pe.RequestToken != nil {
+		e.RequestToken = *pe.RequestToken
+	}
+	return e
+}
+
+// IsOperationFailed reports whether err is an operation that cloud control reported as FAILED.
+func IsOperationFailed(err error) bool {
+	var opErr *OperationError
+	return errors.As(err, &opErr)
+}
+
+// ApiError is a call to the cloud control api that failed before an operation was started, it records the verb and
+// resource the call was for.
+type ApiError struct {
+	Verb       string
+	TypeName   string
+	Identifier string
+	Err        error
+}
+
+func (e *ApiError) Error() string {
+	if e.Identifier == "" {
+		return fmt.Sprintf("%s %s: %s", e.Verb, e.TypeName, e.Err.Error())
+	}
+	return fmt.Sprintf("%s %s %q: %s", e.Verb, e.TypeName, e.Identifier, e.Err.Error())
+}
+
+func (e *ApiError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the HandlerError equivalent to the api's exception.
+func (e *ApiError) Is(target error) bool {
+	h, ok := target.(*HandlerError)
+	if !ok {
+		return false
+	}
+	code, ok := HandlerErrorCode(e.Err)
+	return ok && code == h.Code
+}
+
+func newApiError(verb string, typeName string, id string, err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &ApiError{Verb: verb, TypeName: typeName, Identifier: id, Err: err}
+}
+
+// apiErrorCodes maps the exceptions returned by the cloud control api to the handler error codes that are reported
+// when the same problem is found while an operation is in progress.
+var apiErrorCodes = map[string]typesCC.HandlerErrorCode{
+	"AccessDeniedException":           typesCC.HandlerErrorCodeAccessDenied,
+	"AlreadyExistsException":          typesCC.HandlerErrorCodeAlreadyExists,
+	"ClientTokenConflictException":    HandlerErrorCodeClientTokenConflict,
+	"ConcurrentOperationException":    typesCC.HandlerErrorCodeResourceConflict,
+	"GeneralServiceException":         typesCC.HandlerErrorCodeGeneralServiceException,
+	"HandlerInternalFailureException": typesCC.HandlerErrorCodeInternalFailure,
+	"InvalidCredentialsException":     typesCC.HandlerErrorCodeInvalidCredentials,
+	"InvalidRequestException":         typesCC.HandlerErrorCodeInvalidRequest,
+	"NetworkFailureException":         typesCC.HandlerErrorCodeNetworkFailure,
+	"NotStabilizedException":          typesCC.HandlerErrorCodeNotStabilized,
+	"NotUpdatableException":           typesCC.HandlerErrorCodeNotUpdatable,
+	"ResourceConflictException":       typesCC.HandlerErrorCodeResourceConflict,
+	"ResourceNotFoundException":       typesCC.HandlerErrorCodeNotFound,
+	"ServiceInternalErrorException":   typesCC.HandlerErrorCodeServiceInternalError,
+	"ServiceLimitExceededException":   typesCC.HandlerErrorCodeServiceLimitExceeded,
+	"ThrottlingException":             typesCC.HandlerErrorCodeThrottling,
+}
+
+// HandlerErrorCode returns the cloud control handler error code of a failed operation, or the equivalent code of an
+// error returned by the api.
+func HandlerErrorCode(err error) (typesCC.HandlerErrorCode, bool) {
+	var opErr *OperationError
+	if errors.As(err, &opErr) {
+		return opErr.ErrorCode, opErr.ErrorCode != ""
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code, ok := apiErrorCodes[apiErr.ErrorCode()]
+		return code, ok
+	}
+	return "", false
+}
+
+// AsHandlerError returns the HandlerError that err matches.
+func AsHandlerError(err error) (*HandlerError, bool) {
+	code, ok := HandlerErrorCode(err)
+	if !ok {
+		return nil, false
+	}
+	h, ok := handlerErrors[code]
+	return h, ok
+}
+
+// IsRetryable reports whether err is a transient failure that may not happen again.
+func IsRetryable(err error) bool {
+	h, ok := AsHandlerError(err)
+	return ok && h.Retryable
+}
+
+// IsApiError reports whether err was returned by a call to AWS, rather than found locally.
+func IsApiError(err error) bool {
+	var apiErr smithy.APIError
+	var opErr *smithy.OperationError
+	return errors.As(err, &apiErr) || errors.As(err, &opErr)
+}
+
+// withRetries calls f until it succeeds, fails with an error that isn't retryable, or has been retried maxRetries
+// times. It is only used for idempotent operations, where repeating a request that may have partly succeeded is safe.
+// Only operations that failed in their handler are retried, the client's retryer already retries failed api calls.
+func withRetries(ctx context.Context, f func() error) error {
+	delays := newBackoff()
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || attempt == maxRetries || !IsOperationFailed(err) || !IsRetryable(err) {
+			return err
+		}
+		if sleep(ctx, delays.next(typesCC.ProgressEvent{})) != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package aws
+
+import (
+	"context"
+	typesCC "github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// minPollInterval is the delay before the first status check of an operation
+	minPollInterval = time.Second
+	// maxPollInterval caps the backoff, so long stabilisations are still noticed promptly once they finish
+	maxPollInterval   = 30 * time.Second
+	pollBackoffFactor = 1.5
+	// statusRequestsPerSecond is shared by every operation in progress, bulk operations poll many requests at once
+	statusRequestsPerSecond = 5
+)
+
+// pollLimiter spaces out status checks across goroutines, so that bulk operations are not throttled.
+var pollLimiter = newRateLimiter(statusRequestsPerSecond)
+
+// rateLimiter hands out evenly spaced slots to its callers.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	return &rateLimiter{interval: time.Second / time.Duration(perSecond)}
+}
+
+// wait blocks until the caller's slot, or until ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	return sleep(ctx, time.Until(slot))
+}
+
+// backoff is the delay between status checks of a single operation, it grows exponentially up to maxPollInterval.
+type backoff struct {
+	interval time.Duration
+}
+
+func newBackoff() *backoff {
+	return &backoff{interval: minPollInterval}
+}
+
+// next returns how long to wait before checking the status of pe's request again. The delay is jittered so that
+// operations started together don't poll together, and is never earlier than the request's RetryAfter.
+func (b *backoff) next(pe typesCC.ProgressEvent) time.Duration {
+	half := b.interval / 2
+	delay := half + time.Duration(rand.Int63n(int64(half)+1))
+	b.interval = time.Duration(float64(b.interval) * pollBackoffFactor)
+	if b.interval > maxPollInterval {
+		b.interval = maxPollInterval
+	}
+	if pe.RetryAfter != nil {
+		if retryAfter := time.Until(*pe.RetryAfter); retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+	return delay
+}
+
+// sleep waits for d, returning early with ctx's error if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
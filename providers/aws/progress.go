@@ -0,0 +1,164 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	typesCC "github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"github.com/cheggaaa/pb/v3"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runningTemplate is an operation's line while it runs, the elapsed time ticks between polls
+const runningTemplate = `{{string . "prefix"}} {{string . "status"}} {{etime .}} {{string . "message"}}`
+
+// display shows the progress of resource operations. On a terminal every operation has a line that is updated in place,
+// otherwise a line is logged whenever an operation's status or message changes.
+type display struct {
+	mu   sync.Mutex
+	pool *pb.Pool
+	// output is printed once the live view stops, so that it doesn't interleave with the operation lines
+	output []string
+	users  int
+}
+
+var progress = &display{}
+
+// start begins showing operations, calls can be nested and the live view stops when the last caller stops.
+func (d *display) start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.users++
+	if d.users > 1 || !IsTerminal(os.Stderr) || !IsTerminal(os.Stdout) {
+		return
+	}
+	pool, err := pb.StartPool()
+	if err == nil {
+		d.pool = pool
+	}
+}
+
+func (d *display) stop() {
+	d.mu.Lock()
+	d.users--
+	if d.users > 0 {
+		d.mu.Unlock()
+		return
+	}
+	pool := d.pool
+	d.pool = nil
+	output := d.output
+	d.output = nil
+	d.mu.Unlock()
+	if pool != nil {
+		_ = pool.Stop()
+	}
+	for _, line := range output {
+		fmt.Print(line)
+	}
+}
+
+// printf prints now, or when the live view stops if it is running.
+func (d *display) printf(format string, a ...interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pool != nil {
+		d.output = append(d.output, fmt.Sprintf(format, a...))
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+// operation is the progress of a single resource operation.
+type operation struct {
+	verb     string
+	typeName string
+	id       string
+	started  time.Time
+	bar      *pb.ProgressBar
+	status   string
+	message  string
+	token    string
+}
+
+// startOperation adds a line for an operation to the display.
+func startOperation(verb string, typeName string, id string) *operation {
+	op := &operation{verb: verb, typeName: typeName, id: id, started: time.Now()}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if progress.pool != nil {
+		op.bar = pb.New(0).SetTemplateString(runningTemplate)
+		op.bar.Set("prefix", op.prefix())
+		op.bar.Set("status", "PENDING")
+		progress.pool.Add(op.bar)
+	}
+	return op
+}
+
+func (op *operation) prefix() string {
+	if op.id == "" {
+		return fmt.Sprintf("%s %s", op.verb, op.typeName)
+	}
+	return fmt.Sprintf("%s %s %s", op.verb, op.typeName, op.id)
+}
+
+// update shows the latest progress event for the operation.
+func (op *operation) update(pe typesCC.ProgressEvent) {
+	if pe.Identifier != nil && op.id == "" {
+		op.id = *pe.Identifier
+	}
+	if pe.RequestToken != nil {
+		op.token = *pe.RequestToken
+		if isFinished(pe) {
+			untrackRequest(op.token)
+		} else {
+			trackRequest(PendingRequest{Token: op.token, Verb: op.verb, TypeName: op.typeName, Identifier: op.id})
+		}
+	}
+	status := string(pe.OperationStatus)
+	message := ""
+	if pe.StatusMessage != nil {
+		message = FirstLine(*pe.StatusMessage)
+	}
+	if status == op.status && message == op.message {
+		return
+	}
+	op.status, op.message = status, message
+	if op.bar == nil {
+		elapsed := time.Since(op.started).Round(time.Second)
+		fmt.Fprintf(os.Stderr, "%s %s %s %s\n", op.prefix(), status, elapsed, message)
+		return
+	}
+	op.bar.Set("prefix", op.prefix())
+	op.bar.Set("status", status)
+	op.bar.Set("message", message)
+}
+
+// finish freezes the operation's line with its final status and elapsed time. Unfinished operations stay pending if
+// ctx was cancelled, otherwise they were left running on purpose, eg. with --async.
+func (op *operation) finish(ctx context.Context) {
+	if ctx.Err() == nil && op.token != "" {
+		untrackRequest(op.token)
+	}
+	if op.bar == nil {
+		return
+	}
+	elapsed := time.Since(op.started).Round(time.Second)
+	// messages are shown with the string element rather than in the template, so they can't break template parsing
+	op.bar.Set("final", fmt.Sprintf("%s %s %s %s", op.prefix(), op.status, elapsed, op.message))
+	op.bar.SetTemplateString(`{{string . "final"}}`)
+	op.bar.Finish()
+}
+
+// FirstLine returns the first line of s, eg. of a multi-line status message or description.
+func FirstLine(s string) string {
+	return strings.TrimSpace(strings.SplitN(s, "\n", 2)[0])
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or a pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,61 @@
+package aws
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	"sort"
+	"sync"
+)
+
+// PendingRequest is a cloud control request that cloudctl stopped waiting for before it finished, eg. because it was
+// interrupted or timed out.
+type PendingRequest struct {
+	Token      string
+	Verb       string
+	TypeName   string
+	Identifier string
+}
+
+var pending = struct {
+	sync.Mutex
+	requests map[string]PendingRequest
+}{requests: map[string]PendingRequest{}}
+
+func trackRequest(r PendingRequest) {
+	pending.Lock()
+	defer pending.Unlock()
+	pending.requests[r.Token] = r
+}
+
+func untrackRequest(token string) {
+	pending.Lock()
+	defer pending.Unlock()
+	delete(pending.requests, token)
+}
+
+// PendingRequests returns the requests that were still in progress when cloudctl stopped waiting for them.
+func PendingRequests() []PendingRequest {
+	pending.Lock()
+	defer pending.Unlock()
+	var requests []PendingRequest
+	for _, r := range pending.requests {
+		requests = append(requests, r)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Token < requests[j].Token
+	})
+	return requests
+}
+
+// CancelRequest asks cloud control to cancel a pending request.
+func CancelRequest(ctx context.Context, token string) error {
+	cc, err := NewCcClient(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = cc.CancelResourceRequest(ctx, &cloudcontrol.CancelResourceRequestInput{RequestToken: &token})
+	if err == nil {
+		untrackRequest(token)
+	}
+	return err
+}
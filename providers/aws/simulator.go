@@ -0,0 +1,100 @@
+package aws
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	typesIAM "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"path"
+	"strings"
+)
+
+// PermissionSimulator decides whether the current principal is allowed to perform IAM actions.
+type PermissionSimulator interface {
+	// MissingActions returns the actions that the principal is not allowed to perform.
+	MissingActions(ctx context.Context, actions []string) ([]string, error)
+}
+
+// IamSimulator evaluates the current principal's policies with the IAM policy simulator. Calling it needs
+// iam:SimulatePrincipalPolicy, and iam:GetRole when the credentials are for an assumed role.
+type IamSimulator struct {
+	client    *iam.Client
+	principal string
+}
+
+func NewIamSimulator(ctx context.Context) (*IamSimulator, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+	client := iam.NewFromConfig(cfg)
+	principal, err := principalArn(ctx, client, *identity.Arn)
+	if err != nil {
+		return nil, err
+	}
+	return &IamSimulator{client: client, principal: principal}, nil
+}
+
+// principalArn returns the arn of the role an assumed role session belongs to, the simulator only accepts users, groups
+// and roles. Session arns don't include the role's path, so the role is looked up.
+func principalArn(ctx context.Context, client *iam.Client, arn string) (string, error) {
+	// arn:aws:sts::123456789012:assumed-role/RoleName/SessionName
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return arn, nil
+	}
+	roleName := strings.Split(parts[5], "/")[1]
+	role, err := client.GetRole(ctx, &iam.GetRoleInput{RoleName: &roleName})
+	if err != nil {
+		return "", err
+	}
+	return *role.Role.Arn, nil
+}
+
+func (s *IamSimulator) MissingActions(ctx context.Context, actions []string) ([]string, error) {
+	var missing []string
+	paginator := iam.NewSimulatePrincipalPolicyPaginator(s.client, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: &s.principal,
+		ActionNames:     actions,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.EvaluationResults {
+			if r.EvalDecision != typesIAM.PolicyEvaluationDecisionTypeAllowed {
+				missing = append(missing, *r.EvalActionName)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// StaticSimulator allows the actions it lists, which may use IAM's * and ? wildcards. It stands in for the IAM policy
+// simulator in tests, and checks operations against a known set of actions without calling IAM.
+type StaticSimulator struct {
+	Allowed []string
+}
+
+func (s StaticSimulator) MissingActions(_ context.Context, actions []string) ([]string, error) {
+	var missing []string
+	for _, action := range actions {
+		allowed := false
+		for _, pattern := range s.Allowed {
+			// actions don't contain a /, so path.Match's handling of separators doesn't apply
+			if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(action)); ok {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			missing = append(missing, action)
+		}
+	}
+	return missing, nil
+}